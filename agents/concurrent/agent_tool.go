@@ -0,0 +1,202 @@
+package concurrent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/tmc/langchaingo/agents"
+	"github.com/tmc/langchaingo/callbacks"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/tools"
+)
+
+const (
+	_defaultAgentToolInputKey  = "input"
+	_defaultAgentToolOutputKey = "output"
+	_defaultMaxAgentDepth      = 3
+)
+
+var (
+	// ErrAgentRecursion is returned by an AgentTool called, directly or
+	// through other agent tools, from a run of its own executor.
+	ErrAgentRecursion = errors.New("agent tool called recursively")
+	// ErrAgentDepth is returned by an AgentTool nested deeper than its
+	// MaxDepth.
+	ErrAgentDepth = errors.New("agent tools nested too deep")
+)
+
+// AgentTool exposes an Executor as a tool, so that a supervisor agent can
+// hand sub-tasks to specialized agents. The tool input is the input of the
+// nested run and its output the final answer.
+//
+// The nested run gets the context of the calling action: it is cancelled
+// with it, its spans are children of the action span and its usage is added
+// to the calling run. It also gets the call options of the calling run, so
+// a handler set with chains.WithCallback follows the nested run too. It has
+// a run id of its own, so checkpoints of both runs do not collide.
+type AgentTool struct {
+	Executor         *Executor
+	ToolName         string
+	ToolDescription  string
+	CallbacksHandler callbacks.Handler
+	// InputKey is the input value the tool input is passed as, "input" if
+	// empty.
+	InputKey string
+	// Inputs are extra input values passed to every nested run.
+	Inputs map[string]any
+	// MaxIterations, if positive, caps the iterations of the nested runs
+	// below the MaxIterations of the executor.
+	MaxIterations int
+	// MaxDepth bounds how many agent tools may be nested in one another,
+	// 3 if zero.
+	MaxDepth int
+}
+
+var _ tools.Tool = &AgentTool{}
+
+// NewAgentTool creates an AgentTool running executor under the given name
+// and description.
+func NewAgentTool(name, description string, executor *Executor) *AgentTool {
+	return &AgentTool{
+		Executor:        executor,
+		ToolName:        name,
+		ToolDescription: description,
+	}
+}
+
+func (t *AgentTool) Name() string {
+	return t.ToolName
+}
+
+func (t *AgentTool) Description() string {
+	return t.ToolDescription
+}
+
+// agentCall is a call of an AgentTool in progress, linked to the call of
+// the agent tool whose run made it.
+type agentCall struct {
+	parent *agentCall
+	// caller is the executor of the run making the call.
+	caller   *Executor
+	executor *Executor
+	name     string
+	depth    int
+}
+
+type (
+	agentCallKey   struct{}
+	runExecutorKey struct{}
+)
+
+// withRunExecutor marks ctx as belonging to a run of e.
+func withRunExecutor(ctx context.Context, e *Executor) context.Context {
+	return context.WithValue(ctx, runExecutorKey{}, e)
+}
+
+// enter checks that a nested run of t may start under ctx and returns ctx
+// recording the call.
+func (t *AgentTool) enter(ctx context.Context) (context.Context, error) {
+	parent, _ := ctx.Value(agentCallKey{}).(*agentCall)
+	caller, _ := ctx.Value(runExecutorKey{}).(*Executor)
+	call := &agentCall{parent: parent, caller: caller, executor: t.Executor, name: t.ToolName, depth: 1}
+	if parent != nil {
+		call.depth = parent.depth + 1
+	}
+
+	path := make([]string, 0, call.depth)
+	recursive := false
+	for c := call; c != nil; c = c.parent {
+		path = append(path, c.name)
+		if c.caller == t.Executor || (c != call && c.executor == t.Executor) {
+			recursive = true
+		}
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	if recursive {
+		return ctx, fmt.Errorf("%w: %s", ErrAgentRecursion, strings.Join(path, " -> "))
+	}
+	maxDepth := t.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = _defaultMaxAgentDepth
+	}
+	if call.depth > maxDepth {
+		return ctx, fmt.Errorf("%w: %s exceeds %d levels", ErrAgentDepth, strings.Join(path, " -> "), maxDepth)
+	}
+
+	// The nested run must not reuse the run id given to the calling run.
+	ctx = WithRunID(ctx, "")
+
+	return context.WithValue(ctx, agentCallKey{}, call), nil
+}
+
+// Call runs the executor with input and returns its final answer.
+func (t *AgentTool) Call(ctx context.Context, input string) (string, error) {
+	if t.CallbacksHandler != nil {
+		t.CallbacksHandler.HandleToolStart(ctx, input)
+	}
+
+	output, err := t.run(ctx, input)
+	if err != nil {
+		if t.CallbacksHandler != nil {
+			t.CallbacksHandler.HandleToolError(ctx, err)
+		}
+		return "", err
+	}
+
+	if t.CallbacksHandler != nil {
+		t.CallbacksHandler.HandleToolEnd(ctx, output)
+	}
+
+	return output, nil
+}
+
+func (t *AgentTool) run(ctx context.Context, input string) (string, error) {
+	ctx, err := t.enter(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	inputKey := t.InputKey
+	if inputKey == "" {
+		inputKey = _defaultAgentToolInputKey
+	}
+	inputValues := make(map[string]any, len(t.Inputs)+1)
+	for k, v := range t.Inputs {
+		inputValues[k] = v
+	}
+	inputValues[inputKey] = input
+
+	run, err := t.Executor.startRun(ctx, inputValues, nil, runCallOptions(ctx))
+	if err != nil {
+		return "", err
+	}
+	if t.MaxIterations > 0 && t.MaxIterations < run.maxIterations {
+		run.maxIterations = t.MaxIterations
+	}
+	if parent, ok := ctx.Value(activeRunKey{}).(string); ok {
+		run.log(ctx, slog.LevelDebug, "agent tool called",
+			slog.String("tool", t.ToolName),
+			slog.String("parent_run_id", parent))
+	}
+
+	outputValues, err := t.Executor.execute(ctx, run, make([]schema.AgentStep, 0))
+	if err != nil {
+		return "", fmt.Errorf("agent %s: %w", t.ToolName, err)
+	}
+
+	outputKey := _defaultAgentToolOutputKey
+	if keys := t.Executor.GetOutputKeys(); len(keys) > 0 {
+		outputKey = keys[0]
+	}
+	output, ok := outputValues[outputKey].(string)
+	if !ok {
+		return "", fmt.Errorf("agent %s: %w", t.ToolName, agents.ErrInvalidChainReturnType)
+	}
+
+	return output, nil
+}
@@ -0,0 +1,132 @@
+package concurrent
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tmc/langchaingo/agents"
+	"github.com/tmc/langchaingo/chains"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/tools"
+)
+
+func TestAgentToolCallOptions(t *testing.T) {
+	t.Parallel()
+
+	plan := `{"Actions": [{"Action": "search", "ActionInput": "paris"}]}`
+	llm := &fakeLLM{replies: []*llms.ContentResponse{textReply(plan), textReply(`{"FinalAnswer": "inner done"}`)}}
+	inner := NewExecutor(NewConcurrentAgent(llm, []tools.Tool{newFakeTool("search", nil)}), Options{MaxIterations: 3})
+	supervisor := NewExecutor(&scriptedAgent{
+		tools: []tools.Tool{NewAgentTool("researcher", "researches", inner)},
+		plans: [][]schema.AgentAction{actions("researcher", "find paris")},
+	}, Options{MaxIterations: 3, ReturnIntermediateSteps: true})
+
+	handler := &recordingHandler{}
+	out, err := supervisor.Call(context.Background(), map[string]any{"input": "q"},
+		chains.WithCallback(handler), chains.WithModel("small"))
+	if err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	steps, _ := out[_intermediateStepsOutputKey].([]schema.AgentStep)
+	if got := observations(steps); !slices.Equal(got, []string{"inner done"}) {
+		t.Errorf("observations = %q, want the nested answer", got)
+	}
+
+	events, _ := handler.recorded()
+	for _, want := range []string{"tool start find paris", "tool start paris", "tool end search: paris", "action search"} {
+		if !slices.Contains(events, want) {
+			t.Errorf("handler events = %q, want %q from the nested run", events, want)
+		}
+	}
+	for i, opts := range llm.callOptions() {
+		if opts.Model != "small" {
+			t.Errorf("nested call %d model = %q, want small", i, opts.Model)
+		}
+	}
+}
+
+func TestAgentToolRecursion(t *testing.T) {
+	t.Parallel()
+
+	executor := NewExecutor(&scriptedAgent{}, Options{MaxIterations: 3})
+	self := NewAgentTool("self", "calls itself", executor)
+	if _, err := self.Call(withRunExecutor(context.Background(), executor), "x"); !errors.Is(err, ErrAgentRecursion) {
+		t.Errorf("Call from its own run error = %v, want ErrAgentRecursion", err)
+	}
+
+	// a -> b -> a, through the runs of both executors.
+	a := &scriptedAgent{plans: [][]schema.AgentAction{actions("b", "x")}}
+	b := &scriptedAgent{plans: [][]schema.AgentAction{actions("a", "x")}}
+	executorA := NewExecutor(a, Options{MaxIterations: 3, ErrorPolicy: ErrorPolicyCollectAll, ReturnIntermediateSteps: true})
+	executorB := NewExecutor(b, Options{MaxIterations: 3, ErrorPolicy: ErrorPolicyCollectAll, ReturnIntermediateSteps: true})
+	a.tools = []tools.Tool{NewAgentTool("b", "", executorB)}
+	b.tools = []tools.Tool{NewAgentTool("a", "", executorA)}
+
+	out, err := executorA.Call(context.Background(), map[string]any{"input": "q"})
+	if err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if got := executorB.Agent.(*scriptedAgent).planned(); len(got) != 2 || len(got[1]) != 1 ||
+		!strings.Contains(got[1][0].Observation, ErrAgentRecursion.Error()+": b -> a") {
+		t.Errorf("nested run saw %+v, want the recursive call refused", got)
+	}
+	if steps, _ := out[_intermediateStepsOutputKey].([]schema.AgentStep); !slices.Equal(observations(steps), []string{"done"}) {
+		t.Errorf("observations = %q, want the answer of b", observations(steps))
+	}
+}
+
+func TestAgentToolDepth(t *testing.T) {
+	t.Parallel()
+
+	leaf := NewExecutor(&scriptedAgent{}, Options{MaxIterations: 3})
+	tool := NewAgentTool("leaf", "", leaf)
+	ctx := context.WithValue(context.Background(), agentCallKey{}, &agentCall{name: "outer", depth: 3})
+
+	_, err := tool.Call(ctx, "x")
+	if !errors.Is(err, ErrAgentDepth) || !strings.Contains(err.Error(), "outer -> leaf exceeds 3 levels") {
+		t.Errorf("Call at depth 4 error = %v, want ErrAgentDepth", err)
+	}
+
+	tool.MaxDepth = 4
+	if out, err := tool.Call(ctx, "x"); err != nil || out != "done" {
+		t.Errorf("Call within MaxDepth = %q, %v; want done", out, err)
+	}
+}
+
+func TestAgentToolMaxIterations(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		executorLimit int
+		toolLimit     int
+		wantPlans     int
+	}{
+		{name: "capped by the tool", executorLimit: 5, toolLimit: 2, wantPlans: 2},
+		{name: "not raised by the tool", executorLimit: 2, toolLimit: 5, wantPlans: 2},
+		{name: "executor limit", executorLimit: 3, wantPlans: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			agent := &scriptedAgent{tools: []tools.Tool{newFakeTool("search", nil)}}
+			for range 10 {
+				agent.plans = append(agent.plans, actions("search", "again"))
+			}
+			tool := NewAgentTool("looper", "", NewExecutor(agent, Options{MaxIterations: tt.executorLimit}))
+			tool.MaxIterations = tt.toolLimit
+
+			if _, err := tool.Call(context.Background(), "x"); !errors.Is(err, agents.ErrNotFinished) {
+				t.Errorf("Call error = %v, want ErrNotFinished", err)
+			}
+			if got := len(agent.planned()); got != tt.wantPlans {
+				t.Errorf("nested run planned %d times, want %d", got, tt.wantPlans)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package concurrent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/tmc/langchaingo/schema"
+)
+
+var (
+	// ErrUnknownApproval is returned by AsyncApprover.Decide for an id that
+	// is not pending.
+	ErrUnknownApproval = errors.New("unknown or already decided approval")
+	// ErrDecisionCount is returned when an approver does not return exactly
+	// one decision per action.
+	ErrDecisionCount = errors.New("approver must return one decision per action")
+)
+
+// Verdict is the outcome of the review of an action.
+type Verdict int
+
+const (
+	// VerdictApprove runs the action as planned.
+	VerdictApprove Verdict = iota
+	// VerdictReject skips the action; the reason is returned to the model as
+	// its observation.
+	VerdictReject
+	// VerdictEdit runs the action with ToolInput replacing the planned input.
+	VerdictEdit
+)
+
+// Decision is the review of one planned action.
+type Decision struct {
+	Verdict   Verdict
+	Reason    string
+	ToolInput string
+}
+
+// Approver reviews the actions planned in an iteration before any of them
+// runs. It returns one decision per action, in the same order. Approve may
+// block until a decision is made; it should return when ctx is done.
+type Approver interface {
+	Approve(ctx context.Context, runID string, actions []schema.AgentAction) ([]Decision, error)
+}
+
+// ApproverFunc adapts a function to the Approver interface.
+type ApproverFunc func(ctx context.Context, runID string, actions []schema.AgentAction) ([]Decision, error)
+
+func (f ApproverFunc) Approve(ctx context.Context, runID string, actions []schema.AgentAction) ([]Decision, error) {
+	return f(ctx, runID, actions)
+}
+
+// runPlan runs the actions of a plan after submitting them to the Approver.
+// Rejected actions get the reason as observation and their dependents are
+// skipped, edited ones run with their new input. Steps are returned in plan
+// order. The approver sees the actions with their tool names resolved, and
+// the inputs of dependent actions before the ${id} placeholders are
+// substituted.
+func (e *Executor) runPlan(
+	ctx context.Context,
+	run *runState,
+	actions []schema.AgentAction,
+	deps ActionDependencies,
+) ([]schema.AgentStep, error) {
+	if e.Approver == nil {
+		return e.runActions(ctx, run, actions, deps, nil)
+	}
+
+	decisions, err := e.Approver.Approve(ctx, run.runID, actions)
+	if err != nil {
+		return nil, fmt.Errorf("approve actions: %w", err)
+	}
+	if len(decisions) != len(actions) {
+		return nil, fmt.Errorf("%w: got %d for %d actions", ErrDecisionCount, len(decisions), len(actions))
+	}
+
+	rejected := make(map[int]schema.AgentStep)
+	reviewed := make([]schema.AgentAction, len(actions))
+	for i, decision := range decisions {
+		action := actions[i]
+		switch decision.Verdict {
+		case VerdictReject:
+			rejected[i] = schema.AgentStep{
+				Action:      action,
+				Observation: "The action was rejected by a reviewer and did not run. Reason: " + decision.Reason,
+			}
+			run.emit(ctx, Event{
+				Type:        EventActionRejected,
+				Iteration:   run.iteration,
+				Action:      action,
+				Observation: rejected[i].Observation,
+			})
+		case VerdictEdit:
+			action = editAction(action, decision.ToolInput)
+		case VerdictApprove:
+		default:
+			return nil, fmt.Errorf("unknown verdict %d for action %s", decision.Verdict, action.Tool)
+		}
+		reviewed[i] = action
+	}
+
+	return e.runActions(ctx, run, reviewed, deps, rejected)
+}
+
+// editAction replaces the input of an action, keeping its log in sync when
+// the log is the text form of the action.
+func editAction(action schema.AgentAction, toolInput string) schema.AgentAction {
+	edited := action
+	edited.ToolInput = toolInput
+	if action.Log == actionLog(action) {
+		edited.Log = actionLog(edited)
+	}
+
+	return edited
+}
+
+// PendingApproval is a review waiting for a decision in an AsyncApprover.
+type PendingApproval struct {
+	ID      string
+	RunID   string
+	Actions []schema.AgentAction
+}
+
+// AsyncApprover is an Approver whose decisions come from outside the run,
+// e.g. a UI or a chat message. The run is suspended in Approve until Decide
+// is called with the id of the pending approval or the run's ctx is done.
+type AsyncApprover struct {
+	// Notify, if set, is called when a new approval is pending.
+	Notify func(PendingApproval)
+
+	mu      sync.Mutex
+	pending map[string]*pendingApproval
+}
+
+type pendingApproval struct {
+	PendingApproval
+	decisions chan []Decision
+}
+
+var _ Approver = &AsyncApprover{}
+
+// NewAsyncApprover creates an AsyncApprover calling notify for every new
+// pending approval.
+func NewAsyncApprover(notify func(PendingApproval)) *AsyncApprover {
+	return &AsyncApprover{Notify: notify}
+}
+
+func (a *AsyncApprover) Approve(ctx context.Context, runID string, actions []schema.AgentAction) ([]Decision, error) {
+	p := &pendingApproval{
+		PendingApproval: PendingApproval{
+			ID:      uuid.NewString(),
+			RunID:   runID,
+			Actions: actions,
+		},
+		decisions: make(chan []Decision, 1),
+	}
+	a.mu.Lock()
+	if a.pending == nil {
+		a.pending = make(map[string]*pendingApproval)
+	}
+	a.pending[p.ID] = p
+	a.mu.Unlock()
+
+	defer func() {
+		a.mu.Lock()
+		delete(a.pending, p.ID)
+		a.mu.Unlock()
+	}()
+
+	if a.Notify != nil {
+		a.Notify(p.PendingApproval)
+	}
+
+	select {
+	case decisions := <-p.decisions:
+		return decisions, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Pending returns the approvals waiting for a decision.
+func (a *AsyncApprover) Pending() []PendingApproval {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	pending := make([]PendingApproval, 0, len(a.pending))
+	for _, p := range a.pending {
+		pending = append(pending, p.PendingApproval)
+	}
+
+	return pending
+}
+
+// Decide resumes the run waiting on the approval with the given id.
+func (a *AsyncApprover) Decide(id string, decisions []Decision) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p, ok := a.pending[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownApproval, id)
+	}
+	if len(decisions) != len(p.Actions) {
+		return fmt.Errorf("%w: got %d for %d actions", ErrDecisionCount, len(decisions), len(p.Actions))
+	}
+	delete(a.pending, id)
+	p.decisions <- decisions
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package concurrent
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/tools"
+)
+
+func TestExecutorApprover(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		decision  Decision
+		wantObs   string
+		wantCalls int32
+	}{
+		{
+			name:      "approve",
+			decision:  Decision{Verdict: VerdictApprove},
+			wantObs:   "search: planned",
+			wantCalls: 1,
+		},
+		{
+			name:     "reject",
+			decision: Decision{Verdict: VerdictReject, Reason: "too broad"},
+			wantObs:  "The action was rejected by a reviewer and did not run. Reason: too broad",
+		},
+		{
+			name:      "edit",
+			decision:  Decision{Verdict: VerdictEdit, ToolInput: "edited"},
+			wantObs:   "search: edited",
+			wantCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			search := newFakeTool("search", nil)
+			agent := &scriptedAgent{
+				tools: []tools.Tool{search},
+				plans: [][]schema.AgentAction{actions("search", "planned")},
+			}
+			executor := NewExecutor(agent, Options{
+				MaxIterations: 3,
+				Approver: ApproverFunc(func(context.Context, string, []schema.AgentAction) ([]Decision, error) {
+					return []Decision{tt.decision}, nil
+				}),
+			})
+
+			if _, err := executor.Call(context.Background(), map[string]any{"input": "q"}); err != nil {
+				t.Fatalf("Call: %v", err)
+			}
+			if got := observations(agent.planned()[1]); !slices.Equal(got, []string{tt.wantObs}) {
+				t.Errorf("observations = %q, want %q", got, tt.wantObs)
+			}
+			if got := search.calls.Load(); got != tt.wantCalls {
+				t.Errorf("tool calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestExecutorApproverDecisionCount(t *testing.T) {
+	t.Parallel()
+
+	agent := &scriptedAgent{
+		tools: []tools.Tool{newFakeTool("search", nil)},
+		plans: [][]schema.AgentAction{actions("search", "a", "b")},
+	}
+	executor := NewExecutor(agent, Options{
+		MaxIterations: 3,
+		Approver: ApproverFunc(func(context.Context, string, []schema.AgentAction) ([]Decision, error) {
+			return []Decision{{Verdict: VerdictApprove}}, nil
+		}),
+	})
+
+	if _, err := executor.Call(context.Background(), map[string]any{"input": "q"}); !errors.Is(err, ErrDecisionCount) {
+		t.Errorf("Call error = %v, want ErrDecisionCount", err)
+	}
+}
+
+func TestAsyncApprover(t *testing.T) {
+	t.Parallel()
+
+	search := newFakeTool("search", nil)
+	agent := &scriptedAgent{
+		tools: []tools.Tool{search},
+		plans: [][]schema.AgentAction{actions("search", "a", "b")},
+	}
+	var approver *AsyncApprover
+	approver = NewAsyncApprover(func(p PendingApproval) {
+		if err := approver.Decide("unknown", nil); !errors.Is(err, ErrUnknownApproval) {
+			t.Errorf("Decide unknown id = %v, want ErrUnknownApproval", err)
+		}
+		if err := approver.Decide(p.ID, nil); !errors.Is(err, ErrDecisionCount) {
+			t.Errorf("Decide without decisions = %v, want ErrDecisionCount", err)
+		}
+		if pending := approver.Pending(); len(pending) != 1 || pending[0].ID != p.ID {
+			t.Errorf("Pending = %+v, want %s", pending, p.ID)
+		}
+		// The run waits for the decision, which arrives from elsewhere.
+		go func() {
+			if err := approver.Decide(p.ID, []Decision{{Verdict: VerdictApprove}, {Verdict: VerdictReject}}); err != nil {
+				t.Errorf("Decide: %v", err)
+			}
+		}()
+	})
+	executor := NewExecutor(agent, Options{MaxIterations: 3, Approver: approver})
+
+	if _, err := executor.Call(context.Background(), map[string]any{"input": "q"}); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if got := search.calls.Load(); got != 1 {
+		t.Errorf("tool calls = %d, want only the approved action", got)
+	}
+	if pending := approver.Pending(); len(pending) != 0 {
+		t.Errorf("Pending after the run = %+v, want none", pending)
+	}
+}
+
+func TestAsyncApproverCancel(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	approver := NewAsyncApprover(func(PendingApproval) { cancel() })
+
+	_, err := approver.Approve(ctx, "run", actions("search", "a"))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Approve error = %v, want context.Canceled", err)
+	}
+	if pending := approver.Pending(); len(pending) != 0 {
+		t.Errorf("Pending = %+v, want none", pending)
+	}
+}
@@ -0,0 +1,136 @@
+package concurrent
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/tmc/langchaingo/chains"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// BatchOptions configures Executor.Batch.
+type BatchOptions struct {
+	// MaxConcurrentRuns caps how many runs are in progress at the same time.
+	// Zero means no limit.
+	MaxConcurrentRuns int
+	// MaxConcurrency caps how many actions run at the same time across all
+	// the runs of the batch, the MaxConcurrency of the executor if zero.
+	MaxConcurrency int
+	// ToolConcurrency caps the simultaneous calls per tool across all the
+	// runs of the batch, the ToolConcurrency of the executor if nil.
+	ToolConcurrency map[string]int
+	// Progress, if set, is called each time a run ends. Calls do not
+	// overlap.
+	Progress func(BatchProgress)
+	// CallOptions are passed to every run, like the options of Call.
+	CallOptions []chains.ChainCallOption
+}
+
+// BatchProgress reports a run of a batch that ended.
+type BatchProgress struct {
+	// Index is the position of the run's inputs in the batch.
+	Index int
+	RunID string
+	Err   error
+	// Done counts the runs that ended so far, Failed those of them that
+	// returned an error, out of Total.
+	Done   int
+	Failed int
+	Total  int
+}
+
+// BatchResult is the outcome of one run of a batch.
+type BatchResult struct {
+	RunID  string
+	Output map[string]any
+	// Steps are the intermediate steps of the run, also when it failed.
+	Steps []schema.AgentStep
+	Err   error
+}
+
+// Batch runs the executor once per element of inputs, concurrently, and
+// returns the results in the order of inputs. The actions of all the runs
+// share one limiter built from the options, so the concurrency of the tools
+// is bounded for the whole batch rather than per run. A failing run does not
+// affect the others. Cancelling ctx stops the runs still in progress.
+//
+// Each run gets its own run id: the one set with WithRunID followed by the
+// index of the run, or a new one. Runs load and save Memory like Call, so
+// give the executor a memory without history for unrelated questions.
+func (e *Executor) Batch(ctx context.Context, inputs []map[string]any, opts BatchOptions) []BatchResult {
+	maxConcurrency, toolConcurrency := opts.MaxConcurrency, opts.ToolConcurrency
+	if maxConcurrency == 0 {
+		maxConcurrency = e.MaxConcurrency
+	}
+	if toolConcurrency == nil {
+		toolConcurrency = e.ToolConcurrency
+	}
+	limiter := newActionLimiter(maxConcurrency, toolConcurrency)
+
+	var runs *semaphore
+	if opts.MaxConcurrentRuns > 0 {
+		runs = newSemaphore(opts.MaxConcurrentRuns)
+	}
+	baseRunID, _ := ctx.Value(runIDKey{}).(string)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		progress = BatchProgress{Total: len(inputs)}
+	)
+	results := make([]BatchResult, len(inputs))
+	for i, inputValues := range inputs {
+		runID := uuid.NewString()
+		if baseRunID != "" {
+			runID = fmt.Sprintf("%s-%d", baseRunID, i)
+		}
+		results[i].RunID = runID
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := &results[i]
+			result.Output, result.Steps, result.Err = e.batchRun(WithRunID(ctx, runID), inputValues, opts, limiter, runs)
+
+			mu.Lock()
+			defer mu.Unlock()
+			progress.Done++
+			if result.Err != nil {
+				progress.Failed++
+			}
+			if opts.Progress != nil {
+				progress.Index, progress.RunID, progress.Err = i, runID, result.Err
+				opts.Progress(progress)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (e *Executor) batchRun(
+	ctx context.Context,
+	inputValues map[string]any,
+	opts BatchOptions,
+	limiter *actionLimiter,
+	runs *semaphore,
+) (map[string]any, []schema.AgentStep, error) {
+	if runs != nil {
+		if err := runs.Acquire(ctx); err != nil {
+			return nil, nil, err
+		}
+		defer runs.Release()
+	}
+
+	run, err := e.startRun(ctx, inputValues, nil, opts.CallOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	run.limiter = limiter
+	output, err := e.execute(ctx, run, make([]schema.AgentStep, 0))
+
+	return output, run.steps, err
+}
@@ -0,0 +1,165 @@
+package concurrent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/tools"
+)
+
+// inputAgent plans three actions on its input, then answers with it. Unlike
+// scriptedAgent it can serve concurrent runs. runs measures the runs between
+// their first plan and their answer.
+type inputAgent struct {
+	tools []tools.Tool
+	runs  gauge
+	leave sync.Map
+}
+
+func (a *inputAgent) Plan(
+	_ context.Context,
+	steps []schema.AgentStep,
+	inputs map[string]string,
+) ([]schema.AgentAction, *schema.AgentFinish, error) {
+	input := inputs["input"]
+	if len(steps) > 0 {
+		if leave, ok := a.leave.LoadAndDelete(input); ok {
+			leave.(func())()
+		}
+		return nil, &schema.AgentFinish{ReturnValues: map[string]any{"output": "answer " + input}}, nil
+	}
+	// A failing run never answers: it is left out of the measure.
+	if !strings.HasPrefix(input, "fail") {
+		a.leave.Store(input, a.runs.enter())
+	}
+	planned := make([]schema.AgentAction, 0, 3)
+	for j := range 3 {
+		planned = append(planned, schema.AgentAction{
+			Tool:      "work",
+			ToolInput: fmt.Sprintf("%s/%d", input, j),
+			ToolID:    actionID(0, j),
+		})
+	}
+
+	return planned, nil, nil
+}
+
+func (a *inputAgent) GetInputKeys() []string  { return []string{"input"} }
+func (a *inputAgent) GetOutputKeys() []string { return []string{"output"} }
+func (a *inputAgent) GetTools() []tools.Tool  { return a.tools }
+
+func TestExecutorBatch(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		opts BatchOptions
+		// wantActions and wantRuns bound the peak concurrency, a negative
+		// value asks for more than its opposite.
+		wantActions int
+		wantRuns    int
+	}{
+		{name: "shared limiter", opts: BatchOptions{MaxConcurrency: 2}, wantActions: 2},
+		{name: "max concurrent runs", opts: BatchOptions{MaxConcurrentRuns: 2}, wantRuns: 2},
+		{name: "no limit", wantActions: -3, wantRuns: -2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var actionGauge gauge
+			work := newFakeTool("work", func(_ context.Context, input string) (string, error) {
+				defer actionGauge.enter()()
+				time.Sleep(10 * time.Millisecond)
+				if strings.HasPrefix(input, "fail") {
+					return "", errBoom
+				}
+				return "worked " + input, nil
+			})
+			agent := &inputAgent{tools: []tools.Tool{work}}
+			executor := NewExecutor(agent, Options{MaxIterations: 3})
+
+			inputs := make([]map[string]any, 0, 6)
+			for i := range 6 {
+				input := fmt.Sprintf("q%d", i)
+				if i == 3 {
+					input = "fail"
+				}
+				inputs = append(inputs, map[string]any{"input": input})
+			}
+
+			var (
+				progressGauge gauge
+				progress      []BatchProgress
+			)
+			tt.opts.Progress = func(p BatchProgress) {
+				defer progressGauge.enter()()
+				time.Sleep(time.Millisecond)
+				progress = append(progress, p)
+			}
+			results := executor.Batch(WithRunID(context.Background(), "batch"), inputs, tt.opts)
+
+			if len(results) != len(inputs) {
+				t.Fatalf("%d results, want %d", len(results), len(inputs))
+			}
+			for i, result := range results {
+				if want := fmt.Sprintf("batch-%d", i); result.RunID != want {
+					t.Errorf("result %d RunID = %q, want %q", i, result.RunID, want)
+				}
+				if i == 3 {
+					if !errors.Is(result.Err, errBoom) {
+						t.Errorf("result 3 error = %v, want boom", result.Err)
+					}
+					continue
+				}
+				if want := fmt.Sprintf("answer q%d", i); result.Err != nil || result.Output["output"] != want {
+					t.Errorf("result %d = %v, %v; want %q", i, result.Output["output"], result.Err, want)
+				}
+				if len(result.Steps) != 3 || result.Steps[0].Observation != fmt.Sprintf("worked q%d/0", i) {
+					t.Errorf("result %d steps = %+v, want its own actions", i, result.Steps)
+				}
+			}
+
+			if progressGauge.peak() != 1 {
+				t.Errorf("%d Progress calls overlapped", progressGauge.peak())
+			}
+			indexes := make([]int, 0, len(progress))
+			for i, p := range progress {
+				indexes = append(indexes, p.Index)
+				if p.Done != i+1 || p.Total != len(inputs) || p.RunID != results[p.Index].RunID {
+					t.Errorf("progress %d = %+v", i, p)
+				}
+			}
+			slices.Sort(indexes)
+			if !slices.Equal(indexes, []int{0, 1, 2, 3, 4, 5}) {
+				t.Errorf("progress indexes = %v, want each run once", indexes)
+			}
+			if last := progress[len(progress)-1]; last.Failed != 1 {
+				t.Errorf("last progress Failed = %d, want 1", last.Failed)
+			}
+
+			checkPeak(t, "actions", actionGauge.peak(), tt.wantActions)
+			checkPeak(t, "runs", agent.runs.peak(), tt.wantRuns)
+		})
+	}
+}
+
+// checkPeak checks a peak concurrency: at most want, or more than -want if
+// want is negative.
+func checkPeak(t *testing.T, name string, peak, want int) {
+	t.Helper()
+
+	switch {
+	case want > 0 && peak > want:
+		t.Errorf("peak concurrent %s = %d, want at most %d", name, peak, want)
+	case want < 0 && peak <= -want:
+		t.Errorf("peak concurrent %s = %d, want more than %d", name, peak, -want)
+	}
+}
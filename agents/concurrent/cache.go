@@ -0,0 +1,66 @@
+package concurrent
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/tmc/langchaingo/schema"
+)
+
+// actionCache remembers the observations of the successful actions of a run
+// so that identical actions are not executed twice.
+type actionCache struct {
+	mu    sync.Mutex
+	steps map[string]schema.AgentStep
+}
+
+// actionKey identifies the actions that would produce the same observation.
+func actionKey(action schema.AgentAction) string {
+	return strings.ToUpper(strings.TrimSpace(action.Tool)) + "\x00" + strings.TrimSpace(action.ToolInput)
+}
+
+func (c *actionCache) load(action schema.AgentAction) (schema.AgentStep, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	step, ok := c.steps[actionKey(action)]
+	return step, ok
+}
+
+func (c *actionCache) store(step schema.AgentStep) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.steps == nil {
+		c.steps = make(map[string]schema.AgentStep)
+	}
+	c.steps[actionKey(step.Action)] = step
+}
+
+// reusedStep answers action with the observation of an identical earlier
+// action, noting where the result comes from.
+func reusedStep(action schema.AgentAction, source schema.AgentStep) schema.AgentStep {
+	note := "(Reused result: the same call was already made"
+	if source.Action.ToolID != "" {
+		note += fmt.Sprintf(" as Action[%s]", source.Action.ToolID)
+	}
+	note += ".)"
+
+	return schema.AgentStep{
+		Action:      action,
+		Observation: source.Observation + "\n" + note,
+	}
+}
+
+// cachedIndexes returns the indexes of the steps the cache answers from.
+func (c *actionCache) cachedIndexes(steps []schema.AgentStep) []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var indexes []int
+	for i, step := range steps {
+		if cached, ok := c.steps[actionKey(step.Action)]; ok && cached == step {
+			indexes = append(indexes, i)
+		}
+	}
+
+	return indexes
+}
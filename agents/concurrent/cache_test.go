@@ -0,0 +1,94 @@
+package concurrent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/tools"
+)
+
+func TestExecutorReuseActionResults(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		plans      [][]schema.AgentAction
+		wantCalls  int32
+		wantReused []bool
+	}{
+		{
+			name:       "duplicates in a plan",
+			plans:      [][]schema.AgentAction{actions("search", "x", " x ", "y")},
+			wantCalls:  2,
+			wantReused: []bool{false, true, false},
+		},
+		{
+			name:       "misspelled duplicate in a plan",
+			plans:      [][]schema.AgentAction{{{Tool: "search", ToolInput: "x"}, {Tool: "Serch", ToolInput: "x"}}},
+			wantCalls:  1,
+			wantReused: []bool{false, true},
+		},
+		{
+			name:       "earlier iteration",
+			plans:      [][]schema.AgentAction{actions("search", "x"), actions("SEARCH", "x")},
+			wantCalls:  1,
+			wantReused: []bool{false, true},
+		},
+		{
+			name:       "unknown tools are not deduped",
+			plans:      [][]schema.AgentAction{actions("calculator", "1", "1")},
+			wantReused: []bool{false, false},
+		},
+		{
+			name:       "unknown tools are not cached",
+			plans:      [][]schema.AgentAction{actions("calculator", "1"), actions("calculator", "1")},
+			wantReused: []bool{false, false},
+		},
+		{
+			name: "failures are not cached",
+			plans: [][]schema.AgentAction{
+				actions("search", "fail"),
+				actions("search", "fail"),
+			},
+			wantCalls:  2,
+			wantReused: []bool{false, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			search := newFakeTool("search", func(_ context.Context, input string) (string, error) {
+				if input == "fail" {
+					return "", errBoom
+				}
+				return "found " + input, nil
+			})
+			agent := &scriptedAgent{tools: []tools.Tool{search}, plans: tt.plans}
+			executor := NewExecutor(agent, Options{
+				MaxIterations:      len(tt.plans) + 1,
+				ErrorPolicy:        ErrorPolicyCollectAll,
+				ReuseActionResults: true,
+			})
+
+			if _, err := executor.Call(context.Background(), map[string]any{"input": "q"}); err != nil {
+				t.Fatalf("Call: %v", err)
+			}
+			if got := search.calls.Load(); got != tt.wantCalls {
+				t.Errorf("tool calls = %d, want %d", got, tt.wantCalls)
+			}
+			planned := agent.planned()
+			steps := planned[len(planned)-1]
+			if len(steps) != len(tt.wantReused) {
+				t.Fatalf("got %d steps, want %d", len(steps), len(tt.wantReused))
+			}
+			for i, step := range steps {
+				if got := strings.Contains(step.Observation, "(Reused result"); got != tt.wantReused[i] {
+					t.Errorf("step %d observation %q, reused = %v, want %v", i, step.Observation, got, tt.wantReused[i])
+				}
+			}
+		})
+	}
+}
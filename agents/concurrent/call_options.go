@@ -0,0 +1,138 @@
+package concurrent
+
+import (
+	"context"
+	"reflect"
+	"slices"
+
+	"github.com/tmc/langchaingo/callbacks"
+	"github.com/tmc/langchaingo/chains"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/prompts"
+)
+
+// The fields of chain call options are not exported by langchaingo; they are
+// read back through the chains that apply them.
+
+// callOptionsHandler returns the handler set with chains.WithCallback in
+// options, nil if none.
+func callOptionsHandler(options []chains.ChainCallOption) callbacks.Handler { //nolint:ireturn
+	if len(options) == 0 {
+		return nil
+	}
+
+	return chains.NewLLMChain(nil, nil, options...).CallbacksHandler
+}
+
+type runCallOptionsKey struct{}
+
+// withRunCallOptions records the call options of the run in ctx, for the
+// runs nested in its actions.
+func withRunCallOptions(ctx context.Context, options []chains.ChainCallOption) context.Context {
+	return context.WithValue(ctx, runCallOptionsKey{}, options)
+}
+
+// runCallOptions returns the call options of the run ctx belongs to.
+func runCallOptions(ctx context.Context) []chains.ChainCallOption {
+	options, _ := ctx.Value(runCallOptionsKey{}).([]chains.ChainCallOption)
+	return options
+}
+
+// llmCallOptions translates chain call options into the model call options
+// an LLMChain would use. A handler set with chains.WithCallback is left out:
+// the streaming function of the result is only the one set with
+// chains.WithStreamingFunc, and callers stream to the handler themselves.
+func llmCallOptions(options []chains.ChainCallOption) []llms.CallOption {
+	if len(options) == 0 {
+		return nil
+	}
+	model := &recordingModel{}
+	chain := chains.NewLLMChain(model, prompts.NewPromptTemplate("", nil))
+	options = append(slices.Clip(options), chains.WithCallback(nil))
+	_, _ = chain.Call(context.Background(), map[string]any{}, options...)
+
+	return model.options
+}
+
+// recordingModel records the options of its call instead of generating.
+type recordingModel struct {
+	options []llms.CallOption
+}
+
+func (m *recordingModel) GenerateContent(
+	_ context.Context,
+	_ []llms.MessageContent,
+	options ...llms.CallOption,
+) (*llms.ContentResponse, error) {
+	m.options = options
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{}}}, nil
+}
+
+func (m *recordingModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+// distinctHandlers returns the non-nil handlers, each once.
+func distinctHandlers(handlers ...callbacks.Handler) []callbacks.Handler {
+	distinct := make([]callbacks.Handler, 0, len(handlers))
+	for _, h := range handlers {
+		if h != nil && !slices.ContainsFunc(distinct, func(d callbacks.Handler) bool { return sameHandler(d, h) }) {
+			distinct = append(distinct, h)
+		}
+	}
+
+	return distinct
+}
+
+// sameHandler compares handlers without panicking on handlers of a type that
+// is not comparable, such as callbacks.CombiningHandler.
+func sameHandler(a, b callbacks.Handler) bool {
+	t := reflect.TypeOf(a)
+	return t == reflect.TypeOf(b) && t.Comparable() && a == b
+}
+
+// combineHandlers returns a handler forwarding to every distinct non-nil
+// handler, nil if there is none.
+func combineHandlers(handlers ...callbacks.Handler) callbacks.Handler { //nolint:ireturn
+	switch distinct := distinctHandlers(handlers...); len(distinct) {
+	case 0:
+		return nil
+	case 1:
+		return distinct[0]
+	default:
+		return callbacks.CombiningHandler{Callbacks: distinct}
+	}
+}
+
+// streamFunc returns the streaming function sending the chunks of an agent's
+// output to the handlers and to stream, nil when there is nowhere to send
+// them. Handlers implementing AnswerStreamHandler get the chunks through
+// split, which is only set by agents parsing the TaskFlow as it streams.
+func streamFunc(
+	handlers []callbacks.Handler,
+	stream func(ctx context.Context, chunk []byte) error,
+	split func(chunk []byte),
+) func(ctx context.Context, chunk []byte) error {
+	raw := make([]callbacks.Handler, 0, len(handlers))
+	for _, h := range handlers {
+		if _, ok := h.(AnswerStreamHandler); !ok || split == nil {
+			raw = append(raw, h)
+		}
+	}
+	if len(raw) == 0 && stream == nil && split == nil {
+		return nil
+	}
+
+	return func(ctx context.Context, chunk []byte) error {
+		for _, h := range raw {
+			h.HandleStreamingFunc(ctx, chunk)
+		}
+		if split != nil {
+			split(chunk)
+		}
+		if stream != nil {
+			return stream(ctx, chunk)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,124 @@
+package concurrent
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/tmc/langchaingo/callbacks"
+	"github.com/tmc/langchaingo/chains"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/tools"
+)
+
+// recordingHandler records the agent, tool and streaming events it gets.
+type recordingHandler struct {
+	callbacks.SimpleHandler
+
+	mu     sync.Mutex
+	events []string
+	chunks []string
+}
+
+func (h *recordingHandler) record(event string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events = append(h.events, event)
+}
+
+func (h *recordingHandler) HandleAgentAction(_ context.Context, action schema.AgentAction) {
+	h.record("action " + action.Tool)
+}
+
+func (h *recordingHandler) HandleAgentFinish(_ context.Context, _ schema.AgentFinish) {
+	h.record("finish")
+}
+
+func (h *recordingHandler) HandleToolStart(_ context.Context, input string) {
+	h.record("tool start " + input)
+}
+
+func (h *recordingHandler) HandleToolEnd(_ context.Context, output string) {
+	h.record("tool end " + output)
+}
+
+func (h *recordingHandler) HandleStreamingFunc(_ context.Context, chunk []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.chunks = append(h.chunks, string(chunk))
+}
+
+func (h *recordingHandler) recorded() ([]string, []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return slices.Clone(h.events), slices.Clone(h.chunks)
+}
+
+func TestExecutorCallOptionsHandler(t *testing.T) {
+	t.Parallel()
+
+	for _, speculative := range []bool{false, true} {
+		name := "sequential"
+		if speculative {
+			name = "speculative"
+		}
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			plan := `{"Thought": "look it up", "Actions": [{"Action": "search", "ActionInput": "paris"}]}`
+			llm := &fakeLLM{replies: []*llms.ContentResponse{textReply(plan), textReply(`{"FinalAnswer": "ok"}`)}}
+			agent := NewConcurrentAgent(llm, []tools.Tool{newFakeTool("search", nil)})
+			executor := NewExecutor(agent, Options{MaxIterations: 3, SpeculativeActions: speculative})
+
+			handler := &recordingHandler{}
+			out, err := executor.Call(context.Background(), map[string]any{"input": "where?"},
+				chains.WithCallback(handler), chains.WithModel("small"))
+			if err != nil {
+				t.Fatalf("Call: %v", err)
+			}
+			if out["output"] != "ok" {
+				t.Errorf("output = %v, want ok", out["output"])
+			}
+
+			events, chunks := handler.recorded()
+			for _, want := range []string{"action search", "tool start paris", "tool end search: paris", "finish"} {
+				if !slices.Contains(events, want) {
+					t.Errorf("handler events = %q, want %q", events, want)
+				}
+			}
+			if !slices.Equal(chunks, []string{plan, `{"FinalAnswer": "ok"}`}) {
+				t.Errorf("handler chunks = %q, want both replies", chunks)
+			}
+			for i, opts := range llm.callOptions() {
+				if opts.Model != "small" {
+					t.Errorf("call %d model = %q, want small", i, opts.Model)
+				}
+			}
+		})
+	}
+}
+
+func TestFunctionCallingAgentPlanWithOptions(t *testing.T) {
+	t.Parallel()
+
+	llm := &fakeLLM{replies: []*llms.ContentResponse{textReply("Paris")}}
+	agent := NewFunctionCallingAgent(llm, nil)
+	handler := &recordingHandler{}
+
+	_, finish, err := agent.PlanWithOptions(context.Background(), nil, map[string]string{"input": "capital?"},
+		chains.WithCallback(handler), chains.WithTemperature(0.2))
+	if err != nil {
+		t.Fatalf("PlanWithOptions: %v", err)
+	}
+	if finish == nil || finish.ReturnValues["output"] != "Paris" {
+		t.Errorf("finish = %+v, want output Paris", finish)
+	}
+	if opts := llm.callOptions()[0]; opts.Temperature != 0.2 {
+		t.Errorf("temperature = %v, want 0.2", opts.Temperature)
+	}
+	if _, chunks := handler.recorded(); !slices.Equal(chunks, []string{"Paris"}) {
+		t.Errorf("handler chunks = %q, want [Paris]", chunks)
+	}
+}
@@ -0,0 +1,240 @@
+package concurrent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/tmc/langchaingo/chains"
+	"github.com/tmc/langchaingo/schema"
+)
+
+var (
+	// ErrCheckpointNotFound is returned by Checkpointer.Load for an unknown run.
+	ErrCheckpointNotFound = errors.New("checkpoint not found")
+	// ErrNoCheckpointer is returned by Resume when the executor has no
+	// Checkpointer.
+	ErrNoCheckpointer = errors.New("executor has no checkpointer")
+)
+
+const _runIDOutputKey = "run_id"
+
+// RunError is returned by a run of an executor with a Checkpointer that
+// failed before it ended, so that it can be continued with Resume.
+type RunError struct {
+	RunID string
+	Err   error
+}
+
+func (e *RunError) Error() string {
+	return fmt.Sprintf("run %s: %s", e.RunID, e.Err)
+}
+
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
+
+// Checkpoint is the state of a run after an iteration, enough to continue it.
+type Checkpoint struct {
+	RunID string
+	// Inputs are the prompt inputs of the run, memory variables included.
+	Inputs map[string]string
+	// MemoryInputs are the inputs to save to the memory after a finish. It is
+	// nil when the memory is handled by the caller.
+	MemoryInputs map[string]string
+	Steps        []schema.AgentStep
+	// CachedSteps are the indexes of the Steps that ReuseActionResults
+	// answers identical actions from: the successful tool calls.
+	CachedSteps []int
+	// Iteration is the number of iterations completed.
+	Iteration int
+}
+
+// Checkpointer persists the state of runs so that they can be resumed.
+type Checkpointer interface {
+	// Save stores the checkpoint, replacing the previous one of the run.
+	Save(ctx context.Context, checkpoint Checkpoint) error
+	// Load returns the last checkpoint of the run or ErrCheckpointNotFound.
+	Load(ctx context.Context, runID string) (Checkpoint, error)
+	// Delete removes the checkpoint of a run that finished.
+	Delete(ctx context.Context, runID string) error
+}
+
+type runIDKey struct{}
+
+// WithRunID sets the id of the run started with ctx, so that it can later be
+// resumed with Executor.Resume. Runs without an id get a random one.
+func WithRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, runID)
+}
+
+func runIDFromContext(ctx context.Context) string {
+	if runID, ok := ctx.Value(runIDKey{}).(string); ok && runID != "" {
+		return runID
+	}
+
+	return uuid.NewString()
+}
+
+// Resume continues the run with the given id from its last checkpoint. The
+// iteration counter is restored, so MaxIterations covers the whole run, while
+// MaxExecutionTime starts again. With ReuseActionResults, the cached results of
+// the checkpointed steps are restored too.
+func (e *Executor) Resume(ctx context.Context, runID string, options ...chains.ChainCallOption) (map[string]any, error) {
+	if e.Checkpointer == nil {
+		return nil, ErrNoCheckpointer
+	}
+	checkpoint, err := e.Checkpointer.Load(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	run := e.newRunState(checkpoint.RunID, checkpoint.Inputs, nil, options)
+	run.memoryInputs = checkpoint.MemoryInputs
+	run.iteration = checkpoint.Iteration
+	steps := checkpoint.Steps
+	if steps == nil {
+		steps = make([]schema.AgentStep, 0)
+	}
+	if e.ReuseActionResults {
+		for _, i := range checkpoint.CachedSteps {
+			if i >= 0 && i < len(steps) {
+				run.cache.store(steps[i])
+			}
+		}
+	}
+
+	return e.execute(ctx, run, steps)
+}
+
+func (e *Executor) saveCheckpoint(ctx context.Context, run *runState, steps []schema.AgentStep) error {
+	if e.Checkpointer == nil {
+		return nil
+	}
+	err := e.Checkpointer.Save(ctx, Checkpoint{
+		RunID:        run.runID,
+		Inputs:       run.inputs,
+		MemoryInputs: run.memoryInputs,
+		Steps:        steps,
+		CachedSteps:  run.cache.cachedIndexes(steps),
+		Iteration:    run.iteration + 1,
+	})
+	if err != nil {
+		return fmt.Errorf("save checkpoint of run %s: %w", run.runID, err)
+	}
+
+	return nil
+}
+
+// deleteCheckpoint removes the checkpoint of a run that ended.
+func (e *Executor) deleteCheckpoint(ctx context.Context, run *runState) error {
+	if e.Checkpointer == nil {
+		return nil
+	}
+	if err := e.Checkpointer.Delete(ctx, run.runID); err != nil {
+		return fmt.Errorf("delete checkpoint of run %s: %w", run.runID, err)
+	}
+
+	return nil
+}
+
+// runError wraps the error of a failed run in a *RunError when the run can
+// be resumed.
+func (e *Executor) runError(run *runState, err error) error {
+	if e.Checkpointer == nil {
+		return err
+	}
+
+	return &RunError{RunID: run.runID, Err: err}
+}
+
+// FileCheckpointer stores each checkpoint as a JSON file named after its run
+// id in a directory.
+type FileCheckpointer struct {
+	Dir string
+}
+
+var _ Checkpointer = &FileCheckpointer{}
+
+// NewFileCheckpointer creates a FileCheckpointer, creating dir if needed.
+func NewFileCheckpointer(dir string) (*FileCheckpointer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil { //nolint:mnd
+		return nil, err
+	}
+
+	return &FileCheckpointer{Dir: dir}, nil
+}
+
+func (c *FileCheckpointer) path(runID string) (string, error) {
+	if runID == "" || runID != filepath.Base(runID) || runID == "." || runID == ".." {
+		return "", fmt.Errorf("invalid run id %q", runID)
+	}
+
+	return filepath.Join(c.Dir, runID+".json"), nil
+}
+
+// Save writes the checkpoint to a temporary file and renames it, so a crash
+// never leaves a partial checkpoint behind.
+func (c *FileCheckpointer) Save(_ context.Context, checkpoint Checkpoint) error {
+	path, err := c.path(checkpoint.RunID)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(c.Dir, checkpoint.RunID+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *FileCheckpointer) Load(_ context.Context, runID string) (Checkpoint, error) {
+	var checkpoint Checkpoint
+	path, err := c.path(runID)
+	if err != nil {
+		return checkpoint, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint, fmt.Errorf("%w: %s", ErrCheckpointNotFound, runID)
+	}
+	if err != nil {
+		return checkpoint, err
+	}
+	err = json.Unmarshal(data, &checkpoint)
+
+	return checkpoint, err
+}
+
+func (c *FileCheckpointer) Delete(_ context.Context, runID string) error {
+	path, err := c.path(runID)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
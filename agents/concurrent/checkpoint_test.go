@@ -0,0 +1,149 @@
+package concurrent
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tmc/langchaingo/agents"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/tools"
+)
+
+func TestExecutorResume(t *testing.T) {
+	t.Parallel()
+
+	checkpointer, err := NewFileCheckpointer(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var down atomic.Bool
+	down.Store(true)
+	search := newFakeTool("search", nil)
+	flaky := newFakeTool("flaky", func(_ context.Context, input string) (string, error) {
+		if down.Load() {
+			return "", errBoom
+		}
+		return "flaky: " + input, nil
+	})
+	agent := &scriptedAgent{
+		tools: []tools.Tool{search, flaky},
+		plans: [][]schema.AgentAction{actions("search", "a"), actions("flaky", "b")},
+	}
+	executor := NewExecutor(agent, Options{MaxIterations: 5, Checkpointer: checkpointer})
+
+	ctx := WithRunID(context.Background(), "run-1")
+	_, err = executor.Call(ctx, map[string]any{"input": "q"})
+	var runErr *RunError
+	if !errors.As(err, &runErr) || runErr.RunID != "run-1" || !errors.Is(err, errBoom) {
+		t.Fatalf("Call error = %v, want the RunError of run-1", err)
+	}
+
+	down.Store(false)
+	out, err := executor.Resume(context.Background(), runErr.RunID)
+	if err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	if out["output"] != "done" || out[_runIDOutputKey] != "run-1" {
+		t.Errorf("Resume = %v, want the output and run_id of run-1", out)
+	}
+	if got := search.calls.Load(); got != 1 {
+		t.Errorf("search called %d times, want the checkpointed step to be kept", got)
+	}
+	if _, err := checkpointer.Load(context.Background(), "run-1"); !errors.Is(err, ErrCheckpointNotFound) {
+		t.Errorf("Load after finish = %v, want ErrCheckpointNotFound", err)
+	}
+}
+
+func TestExecutorNotFinishedDeletesCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	checkpointer, err := NewFileCheckpointer(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	agent := &scriptedAgent{
+		tools: []tools.Tool{newFakeTool("search", nil)},
+		plans: [][]schema.AgentAction{actions("search", "a"), actions("search", "b")},
+	}
+	executor := NewExecutor(agent, Options{MaxIterations: 1, Checkpointer: checkpointer})
+
+	out, err := executor.Call(context.Background(), map[string]any{"input": "q"})
+	if !errors.Is(err, agents.ErrNotFinished) {
+		t.Fatalf("Call error = %v, want ErrNotFinished", err)
+	}
+	runID, _ := out[_runIDOutputKey].(string)
+	if runID == "" {
+		t.Fatalf("Call = %v, want a run_id", out)
+	}
+	if _, err := checkpointer.Load(context.Background(), runID); !errors.Is(err, ErrCheckpointNotFound) {
+		t.Errorf("Load = %v, want ErrCheckpointNotFound", err)
+	}
+}
+
+// downAgent fails its plans after the first one while down is set.
+type downAgent struct {
+	*scriptedAgent
+	down *atomic.Bool
+}
+
+func (a downAgent) Plan(
+	ctx context.Context,
+	steps []schema.AgentStep,
+	inputs map[string]string,
+) ([]schema.AgentAction, *schema.AgentFinish, error) {
+	if len(steps) > 0 && a.down.Load() {
+		return nil, nil, errBoom
+	}
+	return a.scriptedAgent.Plan(ctx, steps, inputs)
+}
+
+func TestExecutorResumeReusesActionResults(t *testing.T) {
+	t.Parallel()
+
+	checkpointer, err := NewFileCheckpointer(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	search := newFakeTool("search", func(_ context.Context, input string) (string, error) {
+		if input == "fail" {
+			return "", errBoom
+		}
+		return "found " + input, nil
+	})
+	var down atomic.Bool
+	down.Store(true)
+	agent := &scriptedAgent{
+		tools: []tools.Tool{search},
+		plans: [][]schema.AgentAction{actions("search", "a", "fail"), actions("search", "a", "fail")},
+	}
+	executor := NewExecutor(downAgent{scriptedAgent: agent, down: &down}, Options{
+		MaxIterations:      5,
+		ErrorPolicy:        ErrorPolicyCollectAll,
+		ReuseActionResults: true,
+		Checkpointer:       checkpointer,
+	})
+
+	ctx := WithRunID(context.Background(), "run-1")
+	if _, err := executor.Call(ctx, map[string]any{"input": "q"}); !errors.Is(err, errBoom) {
+		t.Fatalf("Call error = %v, want boom", err)
+	}
+
+	down.Store(false)
+	if _, err := executor.Resume(context.Background(), "run-1"); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	// The resumed plan reuses the checkpointed result of "a" but calls
+	// "fail" again, as failures are not cached.
+	if got := search.calls.Load(); got != 3 {
+		t.Errorf("search called %d times, want 3", got)
+	}
+	planned := agent.planned()
+	last := planned[len(planned)-1]
+	if len(last) != 4 || !strings.Contains(last[2].Observation, "(Reused result:") ||
+		strings.Contains(last[3].Observation, "(Reused result:") {
+		t.Errorf("steps after Resume = %+v, want the result of a reused", last)
+	}
+}
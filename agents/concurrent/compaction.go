@@ -0,0 +1,251 @@
+package concurrent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+const (
+	_defaultTokenEncoding  = "cl100k_base"
+	_charsPerToken         = 4
+	_omittedObservation    = "(observation omitted to stay within the token budget)"
+	_truncatedMarker       = "\n...[truncated]...\n"
+	_summaryPrefix         = "Summary of the earlier steps: "
+	_maxCachedSummaries    = 64
+	_summarizeInstructions = `Summarize the following steps taken by an agent working on a question.
+Keep every fact, number, name and date the answer may need, and say which actions
+failed. Write at most %d words.
+
+%s`
+)
+
+// TokenCounter returns the number of tokens in a text.
+type TokenCounter func(text string) int
+
+// TiktokenCounter counts tokens with the tiktoken encoding of the model,
+// falling back to cl100k_base and, when no encoding can be loaded, to an
+// estimate of four characters per token. The encoding is downloaded on first
+// use unless it is in the TIKTOKEN_CACHE_DIR cache, so call it once at
+// startup rather than during a run.
+func TiktokenCounter(model string) TokenCounter {
+	encoding, err := tiktoken.EncodingForModel(model)
+	if err != nil {
+		encoding, err = tiktoken.GetEncoding(_defaultTokenEncoding)
+	}
+	if err != nil {
+		return approximateTokens
+	}
+
+	return func(text string) int {
+		return len(encoding.Encode(text, nil, nil))
+	}
+}
+
+func approximateTokens(text string) int {
+	return (len([]rune(text)) + _charsPerToken - 1) / _charsPerToken
+}
+
+// countTokens counts with counter, or estimates four characters per token
+// when it is nil: compactors run in the middle of a run, where loading an
+// encoding could stall on the network.
+func countTokens(counter TokenCounter, text string) int {
+	if counter == nil {
+		counter = approximateTokens
+	}
+
+	return counter(text)
+}
+
+// ScratchpadCompactor shrinks the intermediate steps of a run so that the
+// scratchpad rendered from them by format fits a token budget. It is applied
+// before every planning call and never changes the steps of the run itself.
+type ScratchpadCompactor interface {
+	Compact(ctx context.Context, steps []schema.AgentStep, format ScratchpadFormatter) ([]schema.AgentStep, error)
+}
+
+// TruncateOldestCompactor replaces the observations of the oldest steps with
+// a short note until the scratchpad fits MaxTokens. The actions themselves
+// stay, so the model knows they were made.
+type TruncateOldestCompactor struct {
+	MaxTokens int
+	// Counter counts tokens, four characters per token if nil. Use
+	// TiktokenCounter for exact counts.
+	Counter TokenCounter
+}
+
+var _ ScratchpadCompactor = TruncateOldestCompactor{}
+
+func (c TruncateOldestCompactor) Compact(
+	_ context.Context,
+	steps []schema.AgentStep,
+	format ScratchpadFormatter,
+) ([]schema.AgentStep, error) {
+	if c.MaxTokens <= 0 || countTokens(c.Counter, format(steps)) <= c.MaxTokens {
+		return steps, nil
+	}
+
+	steps = slices.Clone(steps)
+	for i := range steps {
+		steps[i].Observation = _omittedObservation
+		if countTokens(c.Counter, format(steps)) <= c.MaxTokens {
+			break
+		}
+	}
+
+	return steps, nil
+}
+
+// HeadTailCompactor cuts the middle out of the longest observations until the
+// scratchpad fits MaxTokens. The budget left by the rest of the scratchpad is
+// shared evenly, so short observations are kept whole.
+type HeadTailCompactor struct {
+	MaxTokens int
+	// Counter counts tokens, four characters per token if nil. Use
+	// TiktokenCounter for exact counts.
+	Counter TokenCounter
+}
+
+var _ ScratchpadCompactor = HeadTailCompactor{}
+
+func (c HeadTailCompactor) Compact(
+	_ context.Context,
+	steps []schema.AgentStep,
+	format ScratchpadFormatter,
+) ([]schema.AgentStep, error) {
+	if c.MaxTokens <= 0 {
+		return steps, nil
+	}
+	total := countTokens(c.Counter, format(steps))
+	if total <= c.MaxTokens {
+		return steps, nil
+	}
+
+	sizes := make([]int, len(steps))
+	observationTokens := 0
+	for i, step := range steps {
+		sizes[i] = countTokens(c.Counter, step.Observation)
+		observationTokens += sizes[i]
+	}
+	limit := fairShare(sizes, max(c.MaxTokens-(total-observationTokens), 0))
+
+	steps = slices.Clone(steps)
+	for i := range steps {
+		if sizes[i] > limit {
+			steps[i].Observation = headTail(steps[i].Observation, sizes[i], limit)
+		}
+	}
+
+	return steps, nil
+}
+
+// fairShare returns the largest per-item limit such that the items, each
+// capped at the limit, fit budget.
+func fairShare(sizes []int, budget int) int {
+	sorted := slices.Clone(sizes)
+	slices.Sort(sorted)
+	for i, size := range sorted {
+		share := budget / (len(sorted) - i)
+		if size > share {
+			return share
+		}
+		budget -= size
+	}
+
+	return budget
+}
+
+// headTail keeps the beginning and the end of a text of the given number of
+// tokens so that about limit tokens remain. Tokens are mapped to characters
+// proportionally.
+func headTail(text string, tokens, limit int) string {
+	runes := []rune(text)
+	keep := len(runes) * limit / max(tokens, 1)
+	keep -= len(_truncatedMarker)
+	if keep <= 0 {
+		return _omittedObservation
+	}
+	head := keep / 2 //nolint:mnd
+
+	return string(runes[:head]) + _truncatedMarker + string(runes[len(runes)-(keep-head):])
+}
+
+// SummarizingCompactor asks LLM to summarize the oldest steps when the
+// scratchpad exceeds MaxTokens. The most recent steps, up to half of the
+// budget, are kept verbatim; the summary takes the place of the others.
+// Summaries are cached, so consecutive iterations over the same steps only
+// call the model once.
+type SummarizingCompactor struct {
+	LLM       llms.Model
+	MaxTokens int
+	// Counter counts tokens, four characters per token if nil. Use
+	// TiktokenCounter for exact counts.
+	Counter TokenCounter
+
+	mu        sync.Mutex
+	summaries map[string]string
+}
+
+var _ ScratchpadCompactor = &SummarizingCompactor{}
+
+func (c *SummarizingCompactor) Compact(
+	ctx context.Context,
+	steps []schema.AgentStep,
+	format ScratchpadFormatter,
+) ([]schema.AgentStep, error) {
+	if c.MaxTokens <= 0 || countTokens(c.Counter, format(steps)) <= c.MaxTokens {
+		return steps, nil
+	}
+
+	half := c.MaxTokens / 2 //nolint:mnd
+	keep := len(steps)
+	for keep > 0 && countTokens(c.Counter, format(steps[keep-1:])) <= half {
+		keep--
+	}
+	older, recent := steps[:keep], steps[keep:]
+	if len(older) == 0 {
+		return steps, nil
+	}
+
+	summary, err := c.summarize(ctx, format(older), half)
+	if err != nil {
+		return nil, fmt.Errorf("summarize scratchpad: %w", err)
+	}
+
+	compacted := make([]schema.AgentStep, 0, len(recent)+1)
+	compacted = append(compacted, schema.AgentStep{Observation: _summaryPrefix + summary})
+
+	return append(compacted, recent...), nil
+}
+
+func (c *SummarizingCompactor) summarize(ctx context.Context, text string, maxTokens int) (string, error) {
+	c.mu.Lock()
+	summary, ok := c.summaries[text]
+	c.mu.Unlock()
+	if ok {
+		return summary, nil
+	}
+
+	// Words are a budget models follow better than tokens.
+	words := max(maxTokens*3/4, 1) //nolint:mnd
+	// The summary counts in the usage of the run like the agent's own calls.
+	prompt := fmt.Sprintf(_summarizeInstructions, words, text)
+	summary, err := llms.GenerateFromSinglePrompt(ctx, usageModel{c.LLM}, prompt)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.summaries == nil || len(c.summaries) >= _maxCachedSummaries {
+		c.summaries = make(map[string]string)
+	}
+	c.summaries[text] = summary
+
+	return summary, nil
+}
@@ -0,0 +1,79 @@
+package concurrent
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+func TestCompactorsFitTheBudget(t *testing.T) {
+	t.Parallel()
+
+	steps := make([]schema.AgentStep, 0, 4)
+	for i, size := range []int{2000, 40, 3000, 40} {
+		steps = append(steps, schema.AgentStep{
+			Action:      schema.AgentAction{Tool: "search", ToolInput: strings.Repeat("q", i+1), ToolID: actionID(0, i)},
+			Observation: strings.Repeat("word ", size/5),
+		})
+	}
+
+	tests := []struct {
+		name      string
+		compactor ScratchpadCompactor
+		keepShort bool
+	}{
+		{name: "truncate oldest", compactor: TruncateOldestCompactor{MaxTokens: 500}},
+		{name: "head tail", compactor: HeadTailCompactor{MaxTokens: 500}, keepShort: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			compacted, err := tt.compactor.Compact(context.Background(), steps, TextScratchpad)
+			if err != nil {
+				t.Fatalf("Compact: %v", err)
+			}
+			if got := countTokens(nil, TextScratchpad(compacted)); got > 500 {
+				t.Errorf("compacted scratchpad has %d tokens, want at most 500", got)
+			}
+			if len(compacted) != len(steps) {
+				t.Errorf("got %d steps, want %d", len(compacted), len(steps))
+			}
+			if tt.keepShort && compacted[1].Observation != steps[1].Observation {
+				t.Errorf("short observation changed to %q", compacted[1].Observation)
+			}
+		})
+	}
+}
+
+func TestSummarizingCompactorRecordsUsage(t *testing.T) {
+	t.Parallel()
+
+	llm := &fakeLLM{replies: []*llms.ContentResponse{textReply("searched q, qq and qqq")}}
+	compactor := &SummarizingCompactor{LLM: llm, MaxTokens: 200}
+	steps := make([]schema.AgentStep, 0, 3)
+	for i := range 3 {
+		steps = append(steps, schema.AgentStep{
+			Action:      schema.AgentAction{Tool: "search", ToolInput: strings.Repeat("q", i+1), ToolID: actionID(0, i)},
+			Observation: strings.Repeat("word ", 100),
+		})
+	}
+
+	recorder := &usageRecorder{}
+	ctx := withUsageRecorder(context.Background(), recorder)
+	for range 2 {
+		compacted, err := compactor.Compact(ctx, steps, TextScratchpad)
+		if err != nil {
+			t.Fatalf("Compact: %v", err)
+		}
+		if summary := compacted[0].Observation; summary != _summaryPrefix+"searched q, qq and qqq" {
+			t.Errorf("first step = %q, want the summary", summary)
+		}
+	}
+	if usage := recorder.snapshot(Prices{}); usage.Calls != 1 || len(llm.sent()) != 1 {
+		t.Errorf("usage counts %d calls for %d model calls, want the one cached summary", usage.Calls, len(llm.sent()))
+	}
+}
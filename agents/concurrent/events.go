@@ -0,0 +1,107 @@
+package concurrent
+
+import (
+	"context"
+	"time"
+
+	"github.com/tmc/langchaingo/chains"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// EventType identifies the kind of an Event.
+type EventType string
+
+const (
+	// EventPlanStarted is sent before the agent is asked for a plan.
+	EventPlanStarted EventType = "plan_started"
+	// EventPlanParsed carries the planned actions, or the finish when the
+	// agent answered.
+	EventPlanParsed EventType = "plan_parsed"
+	// EventActionStarted is sent when an attempt at an action got its
+	// concurrency slot and its tool is about to be called.
+	EventActionStarted EventType = "action_started"
+	// EventActionFinished carries the observation, duration and number of
+	// attempts of an action.
+	EventActionFinished EventType = "action_finished"
+	// EventActionFailed carries the final error, duration and number of
+	// attempts of an action.
+	EventActionFailed EventType = "action_failed"
+	// EventActionRejected carries an action the Approver rejected and the
+	// observation returned to the model in its place.
+	EventActionRejected EventType = "action_rejected"
+	// EventIterationDone carries the steps an iteration added. Err is set when
+	// the plan could not be parsed and was handled by the ErrorHandler.
+	EventIterationDone EventType = "iteration_done"
+	// EventFinished is always the last event of a stream. It carries the
+	// return values and the error of the run, and its RunID like the other
+	// events unless the run could not start.
+	EventFinished EventType = "finished"
+)
+
+// Event reports the progress of an Executor run. Only the fields relevant to
+// the Type are set.
+type Event struct {
+	Type      EventType
+	RunID     string
+	Iteration int
+
+	Actions []schema.AgentAction
+	Finish  *schema.AgentFinish
+
+	Action      schema.AgentAction
+	Attempts    int
+	Observation string
+	Duration    time.Duration
+	// Reused is set on EventActionFinished when the observation was reused
+	// from an identical action instead of calling the tool.
+	Reused bool
+
+	Steps  []schema.AgentStep
+	Output map[string]any
+	Err    error
+}
+
+// Stream runs the executor like Call and reports its progress on the
+// returned channel. The channel is closed after the EventFinished event.
+// Cancelling ctx stops the run; a caller that stops reading must cancel ctx.
+func (e *Executor) Stream(
+	ctx context.Context,
+	inputValues map[string]any,
+	options ...chains.ChainCallOption,
+) <-chan Event {
+	events := make(chan Event, 16) //nolint:mnd
+	go func() {
+		defer close(events)
+		run, err := e.startRun(ctx, inputValues, events, options)
+		if err != nil {
+			sendEvent(ctx, events, Event{Type: EventFinished, Err: err})
+			return
+		}
+		output, err := e.execute(ctx, run, make([]schema.AgentStep, 0))
+		run.emit(ctx, Event{Type: EventFinished, Output: output, Err: err})
+	}()
+
+	return events
+}
+
+// emit sends an event if the run is streamed.
+func (r *runState) emit(ctx context.Context, ev Event) {
+	if r.events != nil {
+		ev.RunID = r.runID
+		sendEvent(ctx, r.events, ev)
+	}
+}
+
+// sendEvent sends ev on events. Once ctx is done the event is only delivered
+// if there is room in the channel buffer.
+func sendEvent(ctx context.Context, events chan<- Event, ev Event) {
+	select {
+	case events <- ev:
+		return
+	default:
+	}
+	select {
+	case events <- ev:
+	case <-ctx.Done():
+	}
+}
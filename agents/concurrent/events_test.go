@@ -0,0 +1,109 @@
+package concurrent
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/tmc/langchaingo/agents"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/tools"
+)
+
+func TestExecutorStream(t *testing.T) {
+	t.Parallel()
+
+	agent := &scriptedAgent{
+		tools: []tools.Tool{newFakeTool("search", nil)},
+		plans: [][]schema.AgentAction{actions("search", "a", "b")},
+	}
+	executor := NewExecutor(agent, Options{MaxIterations: 3})
+
+	var events []Event
+	for ev := range executor.Stream(WithRunID(context.Background(), "run-1"), map[string]any{"input": "q"}) {
+		events = append(events, ev)
+	}
+
+	types := make([]EventType, 0, len(events))
+	for _, ev := range events {
+		types = append(types, ev.Type)
+		if ev.RunID != "run-1" {
+			t.Errorf("%s event RunID = %q, want run-1", ev.Type, ev.RunID)
+		}
+	}
+	want := []EventType{
+		EventPlanStarted, EventPlanParsed,
+		EventActionStarted, EventActionStarted, EventActionFinished, EventActionFinished,
+		EventIterationDone, EventPlanStarted, EventPlanParsed, EventFinished,
+	}
+	// The actions run in parallel: only the start of each action before its
+	// end is certain.
+	if len(types) != len(want) {
+		t.Fatalf("event types = %q, want %q", types, want)
+	}
+	slices.Sort(types[2:6])
+	slices.Sort(want[2:6])
+	if !slices.Equal(types, want) {
+		t.Errorf("event types = %q, want %q", types, want)
+	}
+	started := map[string]int{}
+	for i, ev := range events {
+		switch ev.Type {
+		case EventActionStarted:
+			started[ev.Action.ToolID] = i
+		case EventActionFinished:
+			if _, ok := started[ev.Action.ToolID]; !ok || ev.Observation != "search: "+ev.Action.ToolInput {
+				t.Errorf("action %s finished with %q, want it started first and its output", ev.Action.ToolID, ev.Observation)
+			}
+		}
+	}
+
+	if len(events[1].Actions) != 2 || events[8].Finish == nil {
+		t.Errorf("plan events = %+v and %+v, want the actions then the finish", events[1], events[8])
+	}
+	if steps := events[6].Steps; len(steps) != 2 {
+		t.Errorf("iteration_done steps = %+v, want both actions", steps)
+	}
+	finished := events[len(events)-1]
+	if finished.Err != nil || finished.Output["output"] != "done" {
+		t.Errorf("finished = %+v, want the output", finished)
+	}
+}
+
+func TestExecutorStreamErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		inputs  map[string]any
+		limit   int
+		wantErr error
+		wantRun bool
+	}{
+		{name: "run not started", inputs: map[string]any{"input": struct{}{}}, limit: 3, wantErr: agents.ErrExecutorInputNotString},
+		{name: "run not finished", inputs: map[string]any{"input": "q"}, limit: 1, wantErr: agents.ErrNotFinished, wantRun: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			agent := &scriptedAgent{
+				tools: []tools.Tool{newFakeTool("search", nil)},
+				plans: [][]schema.AgentAction{actions("search", "a"), actions("search", "b")},
+			}
+			var events []Event
+			for ev := range NewExecutor(agent, Options{MaxIterations: tt.limit}).Stream(context.Background(), tt.inputs) {
+				events = append(events, ev)
+			}
+
+			last := events[len(events)-1]
+			if last.Type != EventFinished || !errors.Is(last.Err, tt.wantErr) {
+				t.Fatalf("last event = %+v, want %s with %v", last, EventFinished, tt.wantErr)
+			}
+			if (last.RunID != "") != tt.wantRun || last.RunID != events[0].RunID {
+				t.Errorf("finished RunID = %q, first event RunID = %q", last.RunID, events[0].RunID)
+			}
+		})
+	}
+}
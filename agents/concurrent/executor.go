@@ -6,11 +6,14 @@ import (
 	"fmt"
 	"github.com/tmc/langchaingo/agents"
 	"github.com/tmc/langchaingo/prompts"
+	"log/slog"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/tmc/langchaingo/callbacks"
 	"github.com/tmc/langchaingo/chains"
+	"github.com/tmc/langchaingo/memory"
 	"github.com/tmc/langchaingo/schema"
 	"github.com/tmc/langchaingo/tools"
 )
@@ -24,15 +27,108 @@ type Executor struct {
 	CallbacksHandler callbacks.Handler
 	ErrorHandler     *agents.ParserErrorHandler
 
-	MaxIterations           int
+	MaxIterations int
+	// ReturnIntermediateSteps adds the steps of the run under the
+	// "intermediateSteps" key of the return values, and under
+	// "actionAttempts" the number of attempts each action executed by this
+	// call took, keyed by its ToolID.
 	ReturnIntermediateSteps bool
+	// MaxConcurrency caps how many actions of a plan run at the same time.
+	// Zero means no limit.
+	MaxConcurrency int
+	// ToolConcurrency caps the simultaneous calls per tool, keyed by tool name
+	// (case-insensitive), e.g. {"google_serper": 2, "q-weather": 4}.
+	ToolConcurrency map[string]int
+	// ErrorPolicy decides what happens when an action of a plan fails.
+	ErrorPolicy ErrorPolicy
+	// EarlyStoppingMethod decides what Call returns when the run ends
+	// without a final answer.
+	EarlyStoppingMethod EarlyStoppingMethod
+	// ActionPolicy is the timeout and retry policy of every tool without an
+	// entry in ToolPolicies.
+	ActionPolicy ActionPolicy
+	// ToolPolicies overrides ActionPolicy per tool name (case-insensitive).
+	ToolPolicies map[string]ActionPolicy
+	// Checkpointer, if set, persists the state of a run after every
+	// iteration so that it can be continued with Resume. The id of the run is
+	// returned under the "run_id" key of the return values, and in a
+	// *RunError when the run fails. The checkpoint is deleted once the run
+	// ends, with an answer or with agents.ErrNotFinished.
+	Checkpointer Checkpointer
+	// ReuseActionResults collapses identical (tool, input) actions of a plan
+	// into one call and answers actions already made earlier in the run with
+	// the previous observation. Tools are compared by their resolved name,
+	// and only observations returned by a tool are reused. Leave it off for
+	// tools with side effects.
+	ReuseActionResults bool
+	// MaxExecutionTime bounds the wall-clock time of the iterations of a run.
+	// Reaching it stops the run like exhausting MaxIterations. Zero means no
+	// limit.
+	MaxExecutionTime time.Duration
+	// Approver, if set, reviews the actions of every plan before they run.
+	// Use an AsyncApprover to suspend the run until a decision arrives.
+	Approver Approver
+	// Tracer, if set, records a span for the run, each iteration, each plan
+	// and each executed action.
+	Tracer *Tracer
+	// Logger receives the progress of the runs, slog.Default() if nil.
+	// Records carry the run_id attribute.
+	Logger *slog.Logger
+	// ReturnUsage adds the Usage of the run under the "usage" key of the
+	// return values. A resumed run only counts what happened since Resume.
+	// A CallbacksHandler implementing UsageHandler gets it in any case.
+	ReturnUsage bool
+	// Prices, if set, are used to estimate the Cost of the runs.
+	Prices Prices
+	// ToolAliases maps other names the model may use for a tool to the name
+	// of the tool, e.g. {"search": "google_serper"}. Aliases are compared
+	// case-insensitively, ignoring spaces and punctuation.
+	ToolAliases map[string]string
+	// ToolNameDistance is the number of edits up to which a misspelled tool
+	// name is matched to the closest tool, 2 if zero. A negative value only
+	// allows exact, alias and normalized matches.
+	ToolNameDistance int
+	// SpeculativeActions starts the independent actions of a plan while the
+	// agent is still streaming it, for agents that support it such as
+	// ConcurrentAgent. Actions the final plan drops or changes are cancelled
+	// and reported as failed. It is ignored when an Approver is set. Leave it
+	// off for tools with side effects.
+	SpeculativeActions bool
 }
 
+// EarlyStoppingMethod decides how the executor ends a run that ran out of
+// iterations or time.
+type EarlyStoppingMethod string
+
+const (
+	// EarlyStoppingForce returns agents.ErrNotFinished.
+	EarlyStoppingForce EarlyStoppingMethod = "force"
+	// EarlyStoppingGenerate makes one last LLM call with the steps taken so
+	// far and returns the best answer the model can give. Agents that do not
+	// implement FinalAnswer fall back to EarlyStoppingForce.
+	EarlyStoppingGenerate EarlyStoppingMethod = "generate"
+)
+
+// ErrorPolicy decides how the executor reacts to a failing action.
+type ErrorPolicy int
+
+const (
+	// ErrorPolicyFailFast cancels the sibling actions of the failing one, waits
+	// for them to exit and returns the error from Call.
+	ErrorPolicyFailFast ErrorPolicy = iota
+	// ErrorPolicyCollectAll lets every action finish and turns each tool error
+	// into an observation so the model can recover on the next iteration.
+	ErrorPolicyCollectAll
+)
+
 var (
 	_ chains.Chain           = &Executor{}
 	_ callbacks.HandlerHaver = &Executor{}
 )
 
+// Options configures an Executor. The prompt related fields, OutputKey,
+// SystemMessage and ExtraMessages are read by NewConcurrentAgentWithOptions
+// when passed through WithOptions.
 type Options struct {
 	Prompt                  prompts.PromptTemplate
 	Memory                  schema.Memory
@@ -40,6 +136,23 @@ type Options struct {
 	ErrorHandler            *agents.ParserErrorHandler
 	MaxIterations           int
 	ReturnIntermediateSteps bool
+	MaxConcurrency          int
+	ToolConcurrency         map[string]int
+	ErrorPolicy             ErrorPolicy
+	EarlyStoppingMethod     EarlyStoppingMethod
+	MaxExecutionTime        time.Duration
+	ActionPolicy            ActionPolicy
+	ToolPolicies            map[string]ActionPolicy
+	ReuseActionResults      bool
+	Checkpointer            Checkpointer
+	Approver                Approver
+	Tracer                  *Tracer
+	Logger                  *slog.Logger
+	ReturnUsage             bool
+	Prices                  Prices
+	SpeculativeActions      bool
+	ToolAliases             map[string]string
+	ToolNameDistance        int
 	OutputKey               string
 	PromptPrefix            string
 	FormatInstructions      string
@@ -55,42 +168,301 @@ type Options struct {
 
 // NewExecutor creates a new agent executor with an agent and the tools the agent can use.
 func NewExecutor(agent agents.Agent, options Options) *Executor {
-	return &Executor{
+	executor := &Executor{
 		Agent:                   agent,
 		Memory:                  options.Memory,
 		MaxIterations:           options.MaxIterations,
 		ReturnIntermediateSteps: options.ReturnIntermediateSteps,
 		CallbacksHandler:        options.CallbacksHandler,
 		ErrorHandler:            options.ErrorHandler,
+		MaxConcurrency:          options.MaxConcurrency,
+		ToolConcurrency:         options.ToolConcurrency,
+		ErrorPolicy:             options.ErrorPolicy,
+		EarlyStoppingMethod:     options.EarlyStoppingMethod,
+		MaxExecutionTime:        options.MaxExecutionTime,
+		ActionPolicy:            options.ActionPolicy,
+		ToolPolicies:            options.ToolPolicies,
+		ReuseActionResults:      options.ReuseActionResults,
+		Checkpointer:            options.Checkpointer,
+		Approver:                options.Approver,
+		Tracer:                  options.Tracer,
+		Logger:                  options.Logger,
+		ReturnUsage:             options.ReturnUsage,
+		Prices:                  options.Prices,
+		SpeculativeActions:      options.SpeculativeActions,
+		ToolAliases:             options.ToolAliases,
+		ToolNameDistance:        options.ToolNameDistance,
+	}
+	if executor.Memory == nil {
+		executor.Memory = memory.NewSimple()
+	}
+
+	return executor
+}
+
+// Call runs the agent until it finishes or MaxIterations is reached. The
+// options are passed on to agents implementing PlanWithOptions, such as
+// ConcurrentAgent, for every planning call. A handler given with
+// chains.WithCallback also gets the agent action and finish events, the tool
+// events and the usage of the run, like the CallbacksHandler.
+func (e *Executor) Call(ctx context.Context, inputValues map[string]any, options ...chains.ChainCallOption) (map[string]any, error) { //nolint:lll
+	return e.call(ctx, inputValues, nil, options)
+}
+
+// optionsPlanner is implemented by agents that accept chain call options when
+// planning.
+type optionsPlanner interface {
+	PlanWithOptions(
+		ctx context.Context,
+		intermediateSteps []schema.AgentStep,
+		inputs map[string]string,
+		options ...chains.ChainCallOption,
+	) ([]schema.AgentAction, *schema.AgentFinish, error)
+}
+
+// finalAnswerer is implemented by agents that can give a best-effort answer
+// from the steps taken so far.
+type finalAnswerer interface {
+	FinalAnswer(
+		ctx context.Context,
+		intermediateSteps []schema.AgentStep,
+		inputs map[string]string,
+		options ...chains.ChainCallOption,
+	) (*schema.AgentFinish, error)
+}
+
+// runState holds what the iterations of one run share.
+type runState struct {
+	runID  string
+	inputs map[string]string
+	// memoryInputs are saved to the memory after a finish, nil when the
+	// caller handles the memory.
+	memoryInputs map[string]string
+	callOptions  []chains.ChainCallOption
+	nameToTool   *sync.Map
+	limiter      *actionLimiter
+	events       chan<- Event
+	cache        actionCache
+	logger       *slog.Logger
+	usage        *usageRecorder
+	iteration    int
+	// maxIterations is MaxIterations of the executor unless the run is
+	// capped lower, e.g. by an AgentTool.
+	maxIterations int
+	// steps are the intermediate steps as of the last iteration.
+	steps []schema.AgentStep
+	// speculation holds the actions started while the current plan was
+	// streaming, nil without SpeculativeActions.
+	speculation *speculation
+	attempts    actionAttempts
+	// handler gets the agent events of the run: the CallbacksHandler of the
+	// executor and callHandler, the one given with chains.WithCallback.
+	handler     callbacks.Handler
+	callHandler callbacks.Handler
+}
+
+func (e *Executor) newRunState(
+	runID string,
+	inputs map[string]string,
+	events chan<- Event,
+	callOptions []chains.ChainCallOption,
+) *runState {
+	var nameToTool sync.Map
+	for k, tool := range getNameToTool(e.Agent.GetTools()) {
+		nameToTool.Store(k, tool)
+	}
+
+	callHandler := callOptionsHandler(callOptions)
+	if e.CallbacksHandler != nil && callHandler != nil && sameHandler(e.CallbacksHandler, callHandler) {
+		callHandler = nil
+	}
+
+	return &runState{
+		runID:       runID,
+		inputs:      inputs,
+		callOptions: callOptions,
+		handler:     combineHandlers(e.CallbacksHandler, callHandler),
+		callHandler: callHandler,
+		nameToTool:  &nameToTool,
+		limiter:     newActionLimiter(e.MaxConcurrency, e.ToolConcurrency),
+		events:      events,
+		logger:      loggerOrDefault(e.Logger),
+		usage:       &usageRecorder{},
+
+		maxIterations: e.MaxIterations,
 	}
 }
 
-func (e *Executor) Call(ctx context.Context, inputValues map[string]any, _ ...chains.ChainCallOption) (map[string]any, error) { //nolint:lll
-	inputs, err := inputsToString(inputValues)
+// call runs the agent loop. When the inputs do not already carry the memory
+// variables, the memory is loaded before the run and the exchange is saved
+// after a finish.
+func (e *Executor) call(
+	ctx context.Context,
+	inputValues map[string]any,
+	events chan<- Event,
+	callOptions []chains.ChainCallOption,
+) (map[string]any, error) {
+	run, err := e.startRun(ctx, inputValues, events, callOptions)
 	if err != nil {
 		return nil, err
 	}
-	nameToTool := getNameToTool(e.Agent.GetTools())
 
-	var nameToToolM sync.Map
-	for k, tool := range nameToTool {
-		nameToToolM.Store(k, tool)
+	return e.execute(ctx, run, make([]schema.AgentStep, 0))
+}
+
+// startRun creates the state of a new run from its input values.
+func (e *Executor) startRun(
+	ctx context.Context,
+	inputValues map[string]any,
+	events chan<- Event,
+	callOptions []chains.ChainCallOption,
+) (*runState, error) {
+	fullValues, ownMemory, err := e.loadMemory(ctx, inputValues)
+	if err != nil {
+		return nil, err
 	}
-	steps := make([]schema.AgentStep, 0)
-	for i := 0; i < e.MaxIterations; i++ {
-		var finish map[string]any
-		steps, finish, err = e.doIteration(ctx, steps, &nameToToolM, inputs)
+	inputs, err := inputsToString(fullValues)
+	if err != nil {
+		return nil, err
+	}
+	run := e.newRunState(runIDFromContext(ctx), inputs, events, callOptions)
+	if ownMemory {
+		if run.memoryInputs, err = inputsToString(inputValues); err != nil {
+			return nil, err
+		}
+	}
+
+	return run, nil
+}
+
+// execute runs the loop from the given steps and, after a finish, saves the
+// memory and drops the checkpoint of the run.
+func (e *Executor) execute(
+	ctx context.Context,
+	run *runState,
+	steps []schema.AgentStep,
+) (outputValues map[string]any, err error) {
+	ctx = withActiveRun(ctx, run.runID)
+	ctx = withUsageRecorder(ctx, run.usage)
+	ctx = withRunExecutor(ctx, e)
+	ctx = withRunCallOptions(ctx, run.callOptions)
+	ctx, span := e.Tracer.start(ctx, SpanRun, "run", map[string]any{
+		"run_id":    run.runID,
+		"input":     run.inputs["input"],
+		"iteration": run.iteration,
+	})
+	start := time.Now()
+	run.log(ctx, slog.LevelDebug, "run started", slog.Int("iteration", run.iteration))
+	defer func() {
+		usage := run.usage.snapshot(e.Prices)
+		for key, value := range outputValues {
+			if key != _intermediateStepsOutputKey && key != _actionAttemptsOutputKey && key != _usageOutputKey {
+				span.set("output."+key, value)
+			}
+		}
+		if e.ReturnUsage && outputValues != nil {
+			outputValues[_usageOutputKey] = usage
+		}
+		for _, h := range distinctHandlers(e.CallbacksHandler, run.callHandler) {
+			if handler, ok := h.(UsageHandler); ok {
+				handler.HandleUsage(ctx, run.runID, usage)
+			}
+		}
+		span.set("total_tokens", usage.TotalTokens)
+		span.set("cost", usage.Cost)
+		span.end(ctx, err)
+		if err != nil {
+			run.log(ctx, slog.LevelWarn, "run failed", slog.Duration("duration", time.Since(start)), slog.Any("error", err))
+			return
+		}
+		run.log(ctx, slog.LevelDebug, "run finished", slog.Duration("duration", time.Since(start)))
+	}()
+
+	outputValues, err = e.loop(ctx, run, steps)
+	if e.Checkpointer != nil && outputValues != nil {
+		outputValues[_runIDOutputKey] = run.runID
+	}
+	if errors.Is(err, agents.ErrNotFinished) {
+		// Resuming the run would only stop it again.
+		if err := e.deleteCheckpoint(ctx, run); err != nil {
+			return outputValues, err
+		}
+		return outputValues, err
+	}
+	if err != nil {
+		return outputValues, e.runError(run, err)
+	}
+	if run.memoryInputs != nil {
+		if err := e.saveMemory(ctx, run.memoryInputs, outputValues); err != nil {
+			return outputValues, e.runError(run, err)
+		}
+	}
+	if err := e.deleteCheckpoint(ctx, run); err != nil {
+		return outputValues, err
+	}
+
+	return outputValues, nil
+}
+
+func (e *Executor) loop(ctx context.Context, run *runState, steps []schema.AgentStep) (map[string]any, error) {
+	// The time limit only applies to the iterations, the final answer of
+	// EarlyStoppingGenerate still runs under ctx.
+	iterationCtx := ctx
+	if e.MaxExecutionTime > 0 {
+		var cancel context.CancelFunc
+		iterationCtx, cancel = context.WithTimeout(ctx, e.MaxExecutionTime)
+		defer cancel()
+	}
+
+	for ; run.iteration < run.maxIterations; run.iteration++ {
+		var (
+			finish map[string]any
+			err    error
+		)
+		steps, finish, err = e.doIteration(iterationCtx, run, steps)
+		run.steps = steps
+		if err != nil && iterationCtx.Err() != nil && ctx.Err() == nil {
+			break
+		}
 		if finish != nil || err != nil {
 			return finish, err
 		}
+		if err := e.saveCheckpoint(ctx, run, steps); err != nil {
+			return nil, err
+		}
+	}
+
+	run.log(ctx, slog.LevelInfo, "run stopped early",
+		slog.Int("iterations", run.iteration),
+		slog.String("method", string(e.EarlyStoppingMethod)))
+	return e.stopEarly(ctx, run, steps)
+}
+
+// stopEarly ends a run that ran out of iterations or time according to
+// EarlyStoppingMethod.
+func (e *Executor) stopEarly(
+	ctx context.Context,
+	run *runState,
+	steps []schema.AgentStep,
+) (map[string]any, error) {
+	if answerer, ok := e.Agent.(finalAnswerer); ok && e.EarlyStoppingMethod == EarlyStoppingGenerate {
+		finish, err := answerer.FinalAnswer(ctx, steps, run.inputs, run.callOptions...)
+		if err != nil {
+			return nil, err
+		}
+		if run.handler != nil {
+			run.handler.HandleAgentFinish(ctx, *finish)
+		}
+		return e.getReturn(run, finish, steps), nil
 	}
 
-	if e.CallbacksHandler != nil {
-		e.CallbacksHandler.HandleAgentFinish(ctx, schema.AgentFinish{
+	if run.handler != nil {
+		run.handler.HandleAgentFinish(ctx, schema.AgentFinish{
 			ReturnValues: map[string]any{"output": agents.ErrNotFinished.Error()},
 		})
 	}
 	return e.getReturn(
+		run,
 		&schema.AgentFinish{ReturnValues: make(map[string]any)},
 		steps,
 	), agents.ErrNotFinished
@@ -98,19 +470,42 @@ func (e *Executor) Call(ctx context.Context, inputValues map[string]any, _ ...ch
 
 func (e *Executor) doIteration( // nolint
 	ctx context.Context,
+	run *runState,
 	steps []schema.AgentStep,
-	nameToTool *sync.Map,
-	inputs map[string]string,
-) ([]schema.AgentStep, map[string]any, error) {
-	actions, finish, err := e.Agent.Plan(ctx, steps, inputs)
+) (_ []schema.AgentStep, _ map[string]any, err error) {
+	ctx, span := e.Tracer.start(ctx, SpanIteration, fmt.Sprintf("iteration %d", run.iteration+1), map[string]any{
+		"iteration": run.iteration,
+	})
+	defer func() { span.end(ctx, err) }()
+
+	run.emit(ctx, Event{Type: EventPlanStarted, Iteration: run.iteration})
+	if e.SpeculativeActions && e.Approver == nil {
+		run.speculation = newSpeculation(ctx, e, run)
+		defer func() {
+			run.speculation.discard(ctx)
+			run.speculation = nil
+		}()
+	}
+	actions, deps, finish, err := e.plan(ctx, run, steps)
+	// Tool names are resolved before anything looks at the actions, so that
+	// the Approver, the dedupe, the cache, the checkpoints and the events
+	// all see the tool that will run.
+	actions = e.resolveActions(ctx, run, actions)
+	if finish != nil || err != nil {
+		run.speculation.discard(ctx)
+	} else {
+		run.speculation.keep(ctx, actions, deps)
+	}
 	if errors.Is(err, agents.ErrUnableToParseOutput) && e.ErrorHandler != nil {
 		formattedObservation := err.Error()
 		if e.ErrorHandler.Formatter != nil {
 			formattedObservation = e.ErrorHandler.Formatter(formattedObservation)
 		}
+		run.log(ctx, slog.LevelWarn, "unparsable plan", slog.Int("iteration", run.iteration), slog.Any("error", err))
 		steps = append(steps, schema.AgentStep{
 			Observation: formattedObservation,
 		})
+		run.emit(ctx, Event{Type: EventIterationDone, Iteration: run.iteration, Err: err})
 		return steps, nil, nil
 	}
 	if err != nil {
@@ -120,75 +515,357 @@ func (e *Executor) doIteration( // nolint
 	if len(actions) == 0 && finish == nil {
 		return steps, nil, agents.ErrAgentNoReturn
 	}
+	run.log(ctx, slog.LevelDebug, "plan parsed",
+		slog.Int("iteration", run.iteration),
+		slog.Int("actions", len(actions)),
+		slog.Bool("finished", finish != nil))
+	run.emit(ctx, Event{Type: EventPlanParsed, Iteration: run.iteration, Actions: actions, Finish: finish})
 
 	if finish != nil {
-		if e.CallbacksHandler != nil {
-			e.CallbacksHandler.HandleAgentFinish(ctx, *finish)
-		}
-		return steps, e.getReturn(finish, steps), nil
-	}
-	type inErr struct {
-		Errs   error
-		Action schema.AgentAction
-	}
-	errItem := make(chan inErr, 1)
-	StepList := make(chan schema.AgentStep, len(actions))
-	for _, action := range actions {
-		go func(ac schema.AgentAction, errs chan inErr, stepList chan schema.AgentStep) {
-			step, err := e.doAction(ctx, nameToTool, ac)
-			if err != nil {
-				errs <- inErr{
-					Errs:   err,
-					Action: ac,
+		if run.handler != nil {
+			run.handler.HandleAgentFinish(ctx, *finish)
+		}
+		return steps, e.getReturn(run, finish, steps), nil
+	}
+	actionSteps, err := e.runPlan(ctx, run, actions, deps)
+	if err != nil {
+		return steps, nil, err
+	}
+	steps = append(steps, actionSteps...)
+	run.emit(ctx, Event{Type: EventIterationDone, Iteration: run.iteration, Steps: actionSteps})
+	return steps, nil, nil
+}
+
+func (e *Executor) plan(
+	ctx context.Context,
+	run *runState,
+	steps []schema.AgentStep,
+) (actions []schema.AgentAction, deps ActionDependencies, finish *schema.AgentFinish, err error) {
+	ctx, span := e.Tracer.start(ctx, SpanPlan, "plan", map[string]any{"steps": len(steps)})
+	defer func() {
+		span.set("actions", len(actions))
+		span.set("finished", finish != nil)
+		span.end(ctx, err)
+	}()
+
+	if run.speculation != nil {
+		ctx = withSpeculator(ctx, run.speculation.start)
+	}
+	if planner, ok := e.Agent.(graphPlanner); ok {
+		return planner.PlanGraph(ctx, steps, run.inputs, run.callOptions...)
+	}
+	if planner, ok := e.Agent.(optionsPlanner); ok {
+		actions, finish, err = planner.PlanWithOptions(ctx, steps, run.inputs, run.callOptions...)
+	} else {
+		actions, finish, err = e.Agent.Plan(ctx, steps, run.inputs)
+	}
+
+	return actions, nil, finish, err
+}
+
+// runActions executes the actions of one plan and returns their steps in
+// plan order. Independent actions run in parallel; an action with
+// dependencies starts as soon as they have succeeded, with their observations
+// substituted in its input, and is skipped when one of them did not succeed.
+// settled holds the steps of actions that must not run, such as rejected
+// ones. With ErrorPolicyFailFast the first failure cancels the remaining
+// actions; with ErrorPolicyCollectAll failures become observations. In both
+// cases runActions only returns once every action has exited.
+func (e *Executor) runActions(
+	ctx context.Context,
+	run *runState,
+	actions []schema.AgentAction,
+	deps ActionDependencies,
+	settled map[int]schema.AgentStep,
+) ([]schema.AgentStep, error) {
+	depIndexes, err := dependencyIndexes(actions, deps)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	steps := make([]schema.AgentStep, len(actions))
+	// failed and steps are written by the goroutine of an action before done
+	// is closed, and read by its dependents after.
+	failed := make([]bool, len(actions))
+	done := make([]chan struct{}, len(actions))
+	// duplicates maps the index of a repeated action to its first occurrence.
+	duplicates := make(map[int]int)
+	firstIndex := make(map[string]int)
+	for i, action := range actions {
+		done[i] = make(chan struct{})
+		if step, ok := settled[i]; ok {
+			steps[i] = step
+			failed[i] = true
+			close(done[i])
+			continue
+		}
+		if e.ReuseActionResults && len(depIndexes[i]) == 0 {
+			if tool, _ := e.checkAction(ctx, run, action); tool == nil {
+				continue
+			}
+			key := actionKey(action)
+			if first, ok := firstIndex[key]; ok {
+				duplicates[i] = first
+				continue
+			}
+			firstIndex[key] = i
+		}
+	}
+
+	for i, action := range actions {
+		if _, ok := settled[i]; ok {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, ac schema.AgentAction) {
+			defer wg.Done()
+			defer close(done[i])
+
+			if first, ok := duplicates[i]; ok {
+				select {
+				case <-done[first]:
+				case <-ctx.Done():
+					return
 				}
+				steps[i] = reusedStep(ac, steps[first])
+				failed[i] = failed[first]
+				run.emit(ctx, Event{
+					Type:        EventActionFinished,
+					Iteration:   run.iteration,
+					Action:      ac,
+					Observation: steps[i].Observation,
+					Reused:      true,
+				})
+				return
 			}
-			stepList <- step
-		}(action, errItem, StepList)
 
-	}
+			dependencies := make([]schema.AgentStep, 0, len(depIndexes[i]))
+			for _, d := range depIndexes[i] {
+				select {
+				case <-done[d]:
+				case <-ctx.Done():
+					return
+				}
+				if failed[d] {
+					var err error
+					steps[i], err = skippedStep(ac, actions[d])
+					failed[i] = true
+					run.emit(ctx, Event{Type: EventActionFailed, Iteration: run.iteration, Action: ac, Err: err})
+					return
+				}
+				dependencies = append(dependencies, steps[d])
+			}
+			ac = substituteOutputs(ac, dependencies)
+
+			if e.ReuseActionResults {
+				if cached, ok := run.cache.load(ac); ok {
+					steps[i] = reusedStep(ac, cached)
+					run.emit(ctx, Event{
+						Type:        EventActionFinished,
+						Iteration:   run.iteration,
+						Action:      ac,
+						Observation: steps[i].Observation,
+						Reused:      true,
+					})
+					return
+				}
+			}
 
-	for i := 0; i < len(actions); {
-		select {
-		case errs, ok := <-errItem:
-			if ok {
-				return steps, nil, fmt.Errorf("%s,err:%e", errs.Action.Tool, errs.Errs)
+			var (
+				step schema.AgentStep
+				err  error
+			)
+			if speculative, ok := run.speculation.take(ac); ok {
+				step, err = speculative.wait(ctx)
+			} else {
+				step, err = e.executeAction(ctx, run, ac)
 			}
-		case step, ok := <-StepList:
-			if ok {
-				steps = append(steps, step)
-				i++
+			if err == nil {
+				steps[i] = step
+				// Only observations of the tool are worth reusing; those of
+				// an unknown tool or an invalid input are not cached.
+				if e.ReuseActionResults {
+					if tool, _ := e.checkAction(ctx, run, step.Action); tool != nil {
+						run.cache.store(step)
+					}
+				}
+				return
+			}
+			failed[i] = true
+			if e.ErrorPolicy == ErrorPolicyCollectAll {
+				steps[i] = schema.AgentStep{
+					Action:      ac,
+					Observation: err.Error(),
+				}
+				return
 			}
+			mu.Lock()
+			if firstErr == nil {
+				firstErr = err
+				cancel()
+			}
+			mu.Unlock()
+		}(i, action)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	// Only the caller can have cancelled ctx at this point; the observations
+	// of interrupted actions are meaningless, so report the cancellation.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// executeAction runs an action under the concurrency limits and the
+// ActionPolicy of its tool. A failure after the last attempt is returned as
+// an *ActionError.
+func (e *Executor) executeAction(
+	ctx context.Context,
+	run *runState,
+	action schema.AgentAction,
+) (schema.AgentStep, error) {
+	ctx, span := e.Tracer.start(ctx, SpanAction, action.Tool, map[string]any{
+		"tool":    action.Tool,
+		"tool_id": action.ToolID,
+		"input":   action.ToolInput,
+	})
+	if run.handler != nil {
+		run.handler.HandleAgentAction(ctx, action)
+	}
+	policy := e.policyFor(action.Tool)
+
+	start := time.Now()
+	var (
+		step    schema.AgentStep
+		err     error
+		attempt int
+	)
+	for attempt = 1; ; attempt++ {
+		step, err = e.attemptAction(ctx, run, action, attempt, policy.Timeout)
+		if err == nil || attempt >= policy.Retry.MaxAttempts || ctx.Err() != nil || !policy.Retry.retryable(err) {
+			break
+		}
+		backoff := policy.Retry.backoff(attempt)
+		run.log(ctx, slog.LevelDebug, "retrying action",
+			slog.String("tool", action.Tool),
+			slog.Int("attempt", attempt),
+			slog.Duration("backoff", backoff),
+			slog.Any("error", err))
+		if sleepContext(ctx, backoff) != nil {
+			break
 		}
 	}
-	return steps, nil, nil
+
+	span.set("attempts", attempt)
+	run.attempts.record(action.ToolID, attempt)
+	if err != nil {
+		err = &ActionError{Tool: action.Tool, Attempts: attempt, Err: err}
+		span.end(ctx, err)
+		run.log(ctx, slog.LevelWarn, "action failed",
+			slog.String("tool", action.Tool),
+			slog.String("tool_id", action.ToolID),
+			slog.Int("attempts", attempt),
+			slog.Any("error", err))
+		run.emit(ctx, Event{
+			Type:      EventActionFailed,
+			Iteration: run.iteration,
+			Action:    action,
+			Attempts:  attempt,
+			Duration:  time.Since(start),
+			Err:       err,
+		})
+		return step, err
+	}
+	span.set("observation", step.Observation)
+	span.end(ctx, nil)
+	run.log(ctx, slog.LevelDebug, "action finished",
+		slog.String("tool", action.Tool),
+		slog.String("tool_id", action.ToolID),
+		slog.Int("attempts", attempt),
+		slog.Duration("duration", time.Since(start)))
+	run.emit(ctx, Event{
+		Type:        EventActionFinished,
+		Iteration:   run.iteration,
+		Action:      action,
+		Attempts:    attempt,
+		Observation: step.Observation,
+		Duration:    time.Since(start),
+	})
+	return step, nil
 }
 
-func (e *Executor) doAction(
+// attemptAction makes one attempt at an action, holding a concurrency slot
+// only for the duration of the tool call.
+func (e *Executor) attemptAction(
 	ctx context.Context,
-	nameToTool *sync.Map,
+	run *runState,
 	action schema.AgentAction,
+	attempt int,
+	timeout time.Duration,
 ) (schema.AgentStep, error) {
-	if e.CallbacksHandler != nil {
-		e.CallbacksHandler.HandleAgentAction(ctx, action)
+	release, err := run.limiter.acquire(ctx, action.Tool)
+	if err != nil {
+		return schema.AgentStep{}, err
 	}
-	toolAny, ok := nameToTool.Load(strings.ToUpper(action.Tool))
-	if !ok {
-		return schema.AgentStep{
-			Action:      action,
-			Observation: fmt.Sprintf("%s is not a valid tool1, try another one", action.Tool),
-		}, nil
+	defer release()
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
-	tool, ok := toolAny.(tools.Tool)
-	if !ok {
+	run.emit(ctx, Event{Type: EventActionStarted, Iteration: run.iteration, Action: action, Attempts: attempt})
+	return e.doAction(ctx, run, action)
+}
+
+func (e *Executor) doAction(
+	ctx context.Context,
+	run *runState,
+	action schema.AgentAction,
+) (schema.AgentStep, error) {
+	tool, rejection := e.checkAction(ctx, run, action)
+	if tool == nil {
+		run.log(ctx, slog.LevelDebug, "action not run",
+			slog.String("tool", action.Tool),
+			slog.String("tool_id", action.ToolID),
+			slog.String("observation", rejection))
 		return schema.AgentStep{
 			Action:      action,
-			Observation: fmt.Sprintf("%s is not a valid tool2, try another one", action.Tool),
+			Observation: rejection,
 		}, nil
 	}
-	observation, err := tool.Call(ctx, action.ToolInput)
+	run.usage.addToolCall(tool.Name())
+	input := action.ToolInput
+	if _, ok := tool.(SchemaTool); ok {
+		input = schemaToolInput(input)
+	}
+	// Tools report to their own handlers; the handler of the call options
+	// cannot be set on them.
+	if run.callHandler != nil {
+		run.callHandler.HandleToolStart(ctx, input)
+	}
+	observation, err := tool.Call(ctx, input)
 	if err != nil {
+		if run.callHandler != nil {
+			run.callHandler.HandleToolError(ctx, err)
+		}
 		return schema.AgentStep{}, err
 	}
+	if run.callHandler != nil {
+		run.callHandler.HandleToolEnd(ctx, observation)
+	}
 
 	return schema.AgentStep{
 		Action:      action,
@@ -196,9 +873,31 @@ func (e *Executor) doAction(
 	}, nil
 }
 
-func (e *Executor) getReturn(finish *schema.AgentFinish, steps []schema.AgentStep) map[string]any {
+// checkAction returns the tool of action, or nil and the observation
+// explaining why the action cannot run: its tool is unknown or its input
+// violates the schema of the tool.
+func (e *Executor) checkAction(
+	ctx context.Context,
+	run *runState,
+	action schema.AgentAction,
+) (tools.Tool, string) { //nolint:ireturn
+	tool, ok := e.resolveTool(ctx, run, action.Tool)
+	if !ok {
+		return nil, e.unknownToolObservation(action.Tool)
+	}
+	if schemaTool, ok := tool.(SchemaTool); ok {
+		if violations := validateToolInput(schemaTool.InputSchema(), action.ToolInput); len(violations) > 0 {
+			return nil, invalidInputObservation(schemaTool, violations)
+		}
+	}
+
+	return tool, ""
+}
+
+func (e *Executor) getReturn(run *runState, finish *schema.AgentFinish, steps []schema.AgentStep) map[string]any {
 	if e.ReturnIntermediateSteps {
 		finish.ReturnValues[_intermediateStepsOutputKey] = steps
+		finish.ReturnValues[_actionAttemptsOutputKey] = run.attempts.snapshot()
 	}
 
 	return finish.ReturnValues
@@ -226,7 +925,7 @@ func (e *Executor) GetCallbackHandler() callbacks.Handler { //nolint:ireturn
 func inputsToString(inputValues map[string]any) (map[string]string, error) {
 	inputs := make(map[string]string, len(inputValues))
 	for key, value := range inputValues {
-		valueStr, ok := value.(string)
+		valueStr, ok := memoryValueToString(value)
 		if !ok {
 			return nil, fmt.Errorf("%w: %s", agents.ErrExecutorInputNotString, key)
 		}
@@ -0,0 +1,418 @@
+package concurrent
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tmc/langchaingo/agents"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/tools"
+)
+
+var errBoom = errors.New("boom")
+
+// scriptedAgent returns its plans in order, giving the actions the ids the
+// ConcurrentAgent would, then finishes with "done".
+type scriptedAgent struct {
+	tools []tools.Tool
+	plans [][]schema.AgentAction
+
+	mu    sync.Mutex
+	steps [][]schema.AgentStep
+}
+
+func (a *scriptedAgent) Plan(
+	_ context.Context,
+	intermediateSteps []schema.AgentStep,
+	_ map[string]string,
+) ([]schema.AgentAction, *schema.AgentFinish, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	i := len(a.steps)
+	a.steps = append(a.steps, slices.Clone(intermediateSteps))
+	if i >= len(a.plans) {
+		return nil, &schema.AgentFinish{ReturnValues: map[string]any{"output": "done"}}, nil
+	}
+
+	actions := slices.Clone(a.plans[i])
+	for j := range actions {
+		actions[j].ToolID = actionID(len(intermediateSteps), j)
+		actions[j].Log = actionLog(actions[j])
+	}
+
+	return actions, nil, nil
+}
+
+// planned returns the steps the agent was given at each planning call.
+func (a *scriptedAgent) planned() [][]schema.AgentStep {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return slices.Clone(a.steps)
+}
+
+func (a *scriptedAgent) GetInputKeys() []string  { return []string{"input"} }
+func (a *scriptedAgent) GetOutputKeys() []string { return []string{"output"} }
+func (a *scriptedAgent) GetTools() []tools.Tool  { return a.tools }
+
+type fakeTool struct {
+	name  string
+	calls atomic.Int32
+	call  func(ctx context.Context, input string) (string, error)
+}
+
+func newFakeTool(name string, call func(ctx context.Context, input string) (string, error)) *fakeTool {
+	return &fakeTool{name: name, call: call}
+}
+
+func (t *fakeTool) Name() string        { return t.name }
+func (t *fakeTool) Description() string { return "a fake tool" }
+
+func (t *fakeTool) Call(ctx context.Context, input string) (string, error) {
+	t.calls.Add(1)
+	if t.call == nil {
+		return t.name + ": " + input, nil
+	}
+	return t.call(ctx, input)
+}
+
+func actions(tool string, inputs ...string) []schema.AgentAction {
+	planned := make([]schema.AgentAction, 0, len(inputs))
+	for _, input := range inputs {
+		planned = append(planned, schema.AgentAction{Tool: tool, ToolInput: input})
+	}
+	return planned
+}
+
+func observations(steps []schema.AgentStep) []string {
+	res := make([]string, 0, len(steps))
+	for _, step := range steps {
+		res = append(res, step.Observation)
+	}
+	return res
+}
+
+// gauge tracks the highest number of concurrent callers.
+type gauge struct {
+	mu       sync.Mutex
+	cur, max int
+}
+
+func (g *gauge) enter() func() {
+	g.mu.Lock()
+	g.cur++
+	g.max = max(g.max, g.cur)
+	g.mu.Unlock()
+	return func() {
+		g.mu.Lock()
+		g.cur--
+		g.mu.Unlock()
+	}
+}
+
+func (g *gauge) peak() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.max
+}
+
+func TestExecutorErrorPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		policy    ErrorPolicy
+		wantErr   bool
+		wantObs   []string
+		wantAbort int32
+	}{
+		{
+			name:      "fail fast cancels the siblings",
+			policy:    ErrorPolicyFailFast,
+			wantErr:   true,
+			wantAbort: 2,
+		},
+		{
+			name:    "collect all returns every observation",
+			policy:  ErrorPolicyCollectAll,
+			wantObs: []string{"slow 1", "fail failed: boom", "slow 2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var running, aborted atomic.Int32
+			slow := newFakeTool("slow", func(ctx context.Context, input string) (string, error) {
+				running.Add(1)
+				defer running.Add(-1)
+				select {
+				case <-ctx.Done():
+					aborted.Add(1)
+					return "", ctx.Err()
+				case <-time.After(100 * time.Millisecond):
+					return "slow " + input, nil
+				}
+			})
+			fail := newFakeTool("fail", func(context.Context, string) (string, error) {
+				time.Sleep(10 * time.Millisecond)
+				return "", errBoom
+			})
+			agent := &scriptedAgent{
+				tools: []tools.Tool{slow, fail},
+				plans: [][]schema.AgentAction{{
+					{Tool: "slow", ToolInput: "1"},
+					{Tool: "fail"},
+					{Tool: "slow", ToolInput: "2"},
+				}},
+			}
+			executor := NewExecutor(agent, Options{MaxIterations: 3, ErrorPolicy: tt.policy})
+
+			_, err := executor.Call(context.Background(), map[string]any{"input": "q"})
+			if got := running.Load(); got != 0 {
+				t.Fatalf("%d actions still running after Call returned", got)
+			}
+			if tt.wantErr {
+				var actionErr *ActionError
+				if !errors.As(err, &actionErr) || !errors.Is(err, errBoom) || actionErr.Tool != "fail" {
+					t.Fatalf("Call error = %v, want the ActionError of fail", err)
+				}
+			} else if err != nil {
+				t.Fatalf("Call: %v", err)
+			}
+			if got := aborted.Load(); got != tt.wantAbort {
+				t.Errorf("aborted siblings = %d, want %d", got, tt.wantAbort)
+			}
+			if tt.wantObs != nil {
+				planned := agent.planned()
+				if got := observations(planned[len(planned)-1]); !slices.Equal(got, tt.wantObs) {
+					t.Errorf("observations = %q, want %q", got, tt.wantObs)
+				}
+			}
+		})
+	}
+}
+
+func TestExecutorConcurrencyLimits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		maxConcurrency  int
+		toolConcurrency map[string]int
+		wantGlobal      int
+		wantTool        map[string]int
+	}{
+		{
+			name:           "global limit",
+			maxConcurrency: 2,
+			wantGlobal:     2,
+		},
+		{
+			name:            "per tool limit",
+			toolConcurrency: map[string]int{"A": 1, "b": 3},
+			wantTool:        map[string]int{"a": 1, "b": 3},
+		},
+		{
+			name:            "both limits",
+			maxConcurrency:  3,
+			toolConcurrency: map[string]int{"a": 1},
+			wantGlobal:      3,
+			wantTool:        map[string]int{"a": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var global gauge
+			perTool := map[string]*gauge{"a": {}, "b": {}}
+			newTool := func(name string) tools.Tool {
+				return newFakeTool(name, func(context.Context, string) (string, error) {
+					defer global.enter()()
+					defer perTool[name].enter()()
+					time.Sleep(20 * time.Millisecond)
+					return "ok", nil
+				})
+			}
+			plan := append(actions("a", "1", "2", "3", "4", "5"), actions("b", "1", "2", "3", "4", "5")...)
+			agent := &scriptedAgent{
+				tools: []tools.Tool{newTool("a"), newTool("b")},
+				plans: [][]schema.AgentAction{plan},
+			}
+			executor := NewExecutor(agent, Options{
+				MaxIterations:   3,
+				MaxConcurrency:  tt.maxConcurrency,
+				ToolConcurrency: tt.toolConcurrency,
+			})
+
+			if _, err := executor.Call(context.Background(), map[string]any{"input": "q"}); err != nil {
+				t.Fatalf("Call: %v", err)
+			}
+			if tt.wantGlobal > 0 && global.peak() > tt.wantGlobal {
+				t.Errorf("peak concurrency = %d, want at most %d", global.peak(), tt.wantGlobal)
+			}
+			for name, want := range tt.wantTool {
+				if got := perTool[name].peak(); got > want {
+					t.Errorf("peak concurrency of %s = %d, want at most %d", name, got, want)
+				}
+			}
+			if tt.wantGlobal == 0 && global.peak() < 2 {
+				t.Errorf("peak concurrency = %d, want actions to run in parallel", global.peak())
+			}
+		})
+	}
+}
+
+func TestExecutorEarlyStopping(t *testing.T) {
+	t.Parallel()
+
+	const plan = `{"Actions": [{"Action": "search", "ActionInput": "again"}]}`
+	tests := []struct {
+		name       string
+		method     EarlyStoppingMethod
+		scripted   bool
+		wantOutput string
+		wantErr    error
+		wantCalls  int
+	}{
+		{name: "force", method: EarlyStoppingForce, wantErr: agents.ErrNotFinished, wantCalls: 2},
+		{name: "generate", method: EarlyStoppingGenerate, wantOutput: "best guess", wantCalls: 3},
+		{name: "generate without FinalAnswer", method: EarlyStoppingGenerate, scripted: true, wantErr: agents.ErrNotFinished},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			search := newFakeTool("search", nil)
+			llm := &fakeLLM{replies: []*llms.ContentResponse{
+				textReply(plan), textReply(plan), textReply(`{"Thought": "out of time", "FinalAnswer": "best guess"}`),
+			}}
+			var agent agents.Agent = NewConcurrentAgent(llm, []tools.Tool{search})
+			if tt.scripted {
+				agent = &scriptedAgent{
+					tools: []tools.Tool{search},
+					plans: [][]schema.AgentAction{actions("search", "again"), actions("search", "again")},
+				}
+			}
+			handler := &recordingHandler{}
+			executor := NewExecutor(agent, Options{
+				MaxIterations:       2,
+				EarlyStoppingMethod: tt.method,
+				CallbacksHandler:    handler,
+			})
+
+			out, err := executor.Call(context.Background(), map[string]any{"input": "q"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Call error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && out["output"] != tt.wantOutput {
+				t.Errorf("output = %v, want %q", out["output"], tt.wantOutput)
+			}
+			if got := search.calls.Load(); got != 2 {
+				t.Errorf("search calls = %d, want one per iteration", got)
+			}
+			if events, _ := handler.recorded(); !slices.Contains(events, "finish") {
+				t.Errorf("handler events = %q, want the finish", events)
+			}
+			if tt.scripted {
+				return
+			}
+			sent := llm.sent()
+			if len(sent) != tt.wantCalls {
+				t.Fatalf("%d model calls, want %d", len(sent), tt.wantCalls)
+			}
+			if tt.method == EarlyStoppingGenerate {
+				last := sent[len(sent)-1]
+				prompt := last[len(last)-1].Parts[0].(llms.TextContent).Text
+				if !strings.Contains(prompt, strings.TrimSpace(_finalAnswerInstruction)) ||
+					strings.Count(prompt, "search: again") != 2 {
+					t.Errorf("final prompt = %q, want both observations and the final answer instruction", prompt)
+				}
+			}
+		})
+	}
+}
+
+func TestExecutorMaxExecutionTime(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		policy     ErrorPolicy
+		method     EarlyStoppingMethod
+		parent     time.Duration
+		wantErr    error
+		wantOutput string
+	}{
+		{name: "fail fast, generate", policy: ErrorPolicyFailFast, method: EarlyStoppingGenerate, wantOutput: "best guess"},
+		{name: "collect all, generate", policy: ErrorPolicyCollectAll, method: EarlyStoppingGenerate, wantOutput: "best guess"},
+		{name: "force", policy: ErrorPolicyCollectAll, method: EarlyStoppingForce, wantErr: agents.ErrNotFinished},
+		{
+			name:    "parent deadline first",
+			policy:  ErrorPolicyCollectAll,
+			method:  EarlyStoppingGenerate,
+			parent:  20 * time.Millisecond,
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// The action outlives the time limit and only ends when its
+			// context is done.
+			var stopped atomic.Value
+			slow := newFakeTool("slow", func(ctx context.Context, _ string) (string, error) {
+				<-ctx.Done()
+				stopped.Store(ctx.Err())
+				return "", ctx.Err()
+			})
+			llm := &fakeLLM{replies: []*llms.ContentResponse{
+				textReply(`{"Actions": [{"Action": "slow", "ActionInput": "x"}]}`),
+				textReply(`{"FinalAnswer": "best guess"}`),
+			}}
+			limit := 100 * time.Millisecond
+			ctx := context.Background()
+			if tt.parent > 0 {
+				limit = time.Minute
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.parent)
+				defer cancel()
+			}
+			executor := NewExecutor(NewConcurrentAgent(llm, []tools.Tool{slow}), Options{
+				MaxIterations:       5,
+				MaxExecutionTime:    limit,
+				EarlyStoppingMethod: tt.method,
+				ErrorPolicy:         tt.policy,
+			})
+			start := time.Now()
+			out, err := executor.Call(ctx, map[string]any{"input": "q"})
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("Call took %v, want it stopped by the time limit", elapsed)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Call error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && out["output"] != tt.wantOutput {
+				t.Errorf("output = %v, want %q", out["output"], tt.wantOutput)
+			}
+			if got, _ := stopped.Load().(error); !errors.Is(got, context.DeadlineExceeded) {
+				t.Errorf("action stopped with %v, want the deadline", got)
+			}
+			wantCalls := 2
+			if tt.method == EarlyStoppingForce || tt.parent > 0 {
+				wantCalls = 1
+			}
+			if got := len(llm.sent()); got != wantCalls {
+				t.Errorf("%d model calls, want %d", got, wantCalls)
+			}
+		})
+	}
+}
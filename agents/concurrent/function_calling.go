@@ -0,0 +1,411 @@
+package concurrent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/tmc/langchaingo/agents"
+	"github.com/tmc/langchaingo/callbacks"
+	"github.com/tmc/langchaingo/chains"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/prompts"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/tools"
+)
+
+const (
+	_defaultFunctionCallingSystemMessage = "You are a helpful AI assistant. " +
+		"Call every tool you need at once when the calls do not depend on each other."
+	_functionCallingInputArg = "input"
+)
+
+// FunctionCallingAgent is an agent that plans with the native tool calling of
+// the model instead of a hand-written TaskFlow JSON. Every tool call of one
+// response becomes an action, so the Executor runs them in parallel.
+type FunctionCallingAgent struct {
+	// LLM is the model used to plan. It must support tool calling.
+	LLM llms.Model
+	// Prompt renders the messages sent before the tool call history.
+	Prompt prompts.FormatPrompter
+	// Tools is a list of the tools the agent can use.
+	Tools []tools.Tool
+	// Output key is the key where the final output is placed.
+	OutputKey string
+	// CallbacksHandler is the handler for callbacks.
+	CallbacksHandler callbacks.Handler
+	// Logger receives the model responses at debug level, slog.Default() if
+	// nil.
+	Logger *slog.Logger
+}
+
+var _ agents.Agent = (*FunctionCallingAgent)(nil)
+
+// NewFunctionCallingAgent creates a new FunctionCallingAgent. Only the output
+// key, callbacks handler, system message and extra messages options apply.
+func NewFunctionCallingAgent(llm llms.Model, tools []tools.Tool, opts ...AgentOption) *FunctionCallingAgent {
+	options := concurrentDefaultOptions()
+	options.systemMessage = _defaultFunctionCallingSystemMessage
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &FunctionCallingAgent{
+		LLM:              llm,
+		Prompt:           createFunctionCallingPrompt(options),
+		Tools:            tools,
+		OutputKey:        options.outputKey,
+		CallbacksHandler: options.callbacksHandler,
+		Logger:           options.logger,
+	}
+}
+
+// Plan decides what actions to take or returns the final result of the input.
+func (a *FunctionCallingAgent) Plan(
+	ctx context.Context,
+	intermediateSteps []schema.AgentStep,
+	inputs map[string]string,
+) ([]schema.AgentAction, *schema.AgentFinish, error) {
+	return a.PlanWithOptions(ctx, intermediateSteps, inputs)
+}
+
+// PlanWithOptions is Plan with chain call options, such as temperature, max
+// tokens, model, stop words or a callbacks handler, applied to the LLM call.
+// A handler given with chains.WithCallback receives the streamed chunks like
+// the CallbacksHandler of the agent.
+func (a *FunctionCallingAgent) PlanWithOptions(
+	ctx context.Context,
+	intermediateSteps []schema.AgentStep,
+	inputs map[string]string,
+	options ...chains.ChainCallOption,
+) ([]schema.AgentAction, *schema.AgentFinish, error) {
+	messages, err := a.messages(intermediateSteps, inputs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	callOptions := append(a.callOptions(options), llms.WithTools(a.llmTools()))
+	result, err := usageModel{a.LLM}.GenerateContent(ctx, messages, callOptions...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(result.Choices) > 0 {
+		loggerOrDefault(a.Logger).DebugContext(ctx, "plan output",
+			slog.String("output", result.Choices[0].Content),
+			slog.Int("tool_calls", len(result.Choices[0].ToolCalls)))
+	}
+
+	return a.ParseOutput(result)
+}
+
+// FinalAnswer asks the model, without tools, for its best answer given the
+// steps taken so far.
+func (a *FunctionCallingAgent) FinalAnswer(
+	ctx context.Context,
+	intermediateSteps []schema.AgentStep,
+	inputs map[string]string,
+	options ...chains.ChainCallOption,
+) (*schema.AgentFinish, error) {
+	messages, err := a.messages(intermediateSteps, inputs)
+	if err != nil {
+		return nil, err
+	}
+	messages = append(messages, llms.TextParts(llms.ChatMessageTypeHuman, _finalAnswerInstruction))
+
+	result, err := usageModel{a.LLM}.GenerateContent(ctx, messages, a.callOptions(options)...)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Choices) == 0 {
+		return nil, agents.ErrAgentNoReturn
+	}
+
+	return &schema.AgentFinish{
+		ReturnValues: map[string]any{
+			a.OutputKey: result.Choices[0].Content,
+		},
+		Log: result.Choices[0].Content,
+	}, nil
+}
+
+func (a *FunctionCallingAgent) messages(
+	intermediateSteps []schema.AgentStep,
+	inputs map[string]string,
+) ([]llms.MessageContent, error) {
+	fullInputs := make(map[string]any, len(inputs))
+	for key, value := range inputs {
+		fullInputs[key] = value
+	}
+
+	prompt, err := a.Prompt.FormatPrompt(fullInputs)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]llms.MessageContent, 0, len(prompt.Messages())+len(intermediateSteps))
+	for _, msg := range prompt.Messages() {
+		messages = append(messages, llms.TextParts(msg.GetType(), msg.GetContent()))
+	}
+
+	return append(messages, a.constructToolMessages(intermediateSteps)...), nil
+}
+
+// callOptions translates chain call options into model call options whose
+// streaming function also sends the chunks to the callbacks handlers of the
+// agent and of the options.
+func (a *FunctionCallingAgent) callOptions(options []chains.ChainCallOption) []llms.CallOption {
+	callOptions := llmCallOptions(options)
+	var llmOptions llms.CallOptions
+	for _, opt := range callOptions {
+		opt(&llmOptions)
+	}
+	handlers := distinctHandlers(a.CallbacksHandler, callOptionsHandler(options))
+
+	return append(callOptions, llms.WithStreamingFunc(streamFunc(handlers, llmOptions.StreamingFunc, nil)))
+}
+
+// ParseOutput maps the tool calls of the first choice to actions, or returns
+// a finish when the model answered without calling a tool.
+func (a *FunctionCallingAgent) ParseOutput(resp *llms.ContentResponse) (
+	[]schema.AgentAction, *schema.AgentFinish, error,
+) {
+	if resp == nil || len(resp.Choices) == 0 {
+		return nil, nil, agents.ErrAgentNoReturn
+	}
+	choice := resp.Choices[0]
+
+	if len(choice.ToolCalls) == 0 {
+		return nil, &schema.AgentFinish{
+			ReturnValues: map[string]any{
+				a.OutputKey: choice.Content,
+			},
+			Log: choice.Content,
+		}, nil
+	}
+
+	// Every action of the plan keeps the whole assistant turn in its log, so
+	// the turn can be replayed verbatim on the next call.
+	turn, err := marshalToolCalls(choice.ToolCalls)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	actions := make([]schema.AgentAction, 0, len(choice.ToolCalls))
+	for _, call := range choice.ToolCalls {
+		if call.FunctionCall == nil {
+			continue
+		}
+		toolInput, err := functionCallInput(call.FunctionCall.Arguments, a.hasInputSchema(call.FunctionCall.Name))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s: %s", agents.ErrUnableToParseOutput, call.FunctionCall.Name, err)
+		}
+		actions = append(actions, schema.AgentAction{
+			Tool:      call.FunctionCall.Name,
+			ToolInput: toolInput,
+			Log:       turn,
+			ToolID:    call.ID,
+		})
+	}
+	if len(actions) == 0 {
+		return nil, nil, agents.ErrAgentNoReturn
+	}
+
+	return actions, nil, nil
+}
+
+func (a *FunctionCallingAgent) GetInputKeys() []string {
+	promptInputs := a.Prompt.GetInputVariables()
+
+	// The history comes from the memory.
+	agentInput := make([]string, 0, len(promptInputs))
+	for _, v := range promptInputs {
+		if v != ChatHistoryKey {
+			agentInput = append(agentInput, v)
+		}
+	}
+
+	return agentInput
+}
+
+func (a *FunctionCallingAgent) GetOutputKeys() []string {
+	return []string{a.OutputKey}
+}
+
+func (a *FunctionCallingAgent) GetTools() []tools.Tool {
+	return a.Tools
+}
+
+func (a *FunctionCallingAgent) llmTools() []llms.Tool {
+	res := make([]llms.Tool, 0, len(a.Tools))
+	for _, tool := range a.Tools {
+		var parameters any = map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				_functionCallingInputArg: map[string]any{"type": "string", "description": "the input to the tool"},
+			},
+			"required": []string{_functionCallingInputArg},
+		}
+		if schemaTool, ok := tool.(SchemaTool); ok {
+			parameters = schemaTool.InputSchema()
+		}
+		res = append(res, llms.Tool{
+			Type: "function",
+			Function: &llms.FunctionDefinition{
+				Name:        tool.Name(),
+				Description: tool.Description(),
+				Parameters:  parameters,
+			},
+		})
+	}
+
+	return res
+}
+
+// constructToolMessages replays the previous plans: one assistant message with
+// the tool calls of a plan followed by one tool message per call. Consecutive
+// steps sharing a log belong to the same plan.
+func (a *FunctionCallingAgent) constructToolMessages(steps []schema.AgentStep) []llms.MessageContent {
+	messages := make([]llms.MessageContent, 0, len(steps)+1)
+	for i := 0; i < len(steps); {
+		if steps[i].Action.ToolID == "" {
+			messages = append(messages, llms.TextParts(llms.ChatMessageTypeHuman, steps[i].Observation))
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(steps) && steps[j].Action.ToolID != "" && steps[j].Action.Log == steps[i].Action.Log {
+			j++
+		}
+		calls, err := unmarshalToolCalls(steps[i].Action.Log)
+		if err != nil || len(calls) == 0 {
+			calls = a.toolCallsFromSteps(steps[i:j])
+		}
+		turn := llms.MessageContent{Role: llms.ChatMessageTypeAI}
+		for _, call := range calls {
+			turn.Parts = append(turn.Parts, call)
+		}
+		messages = append(messages, turn)
+		for _, step := range steps[i:j] {
+			messages = append(messages, llms.MessageContent{
+				Role: llms.ChatMessageTypeTool,
+				Parts: []llms.ContentPart{llms.ToolCallResponse{
+					ToolCallID: step.Action.ToolID,
+					Name:       step.Action.Tool,
+					Content:    step.Observation,
+				}},
+			})
+		}
+		i = j
+	}
+
+	return messages
+}
+
+// loggedToolCall is the log form of a llms.ToolCall. llms.ToolCall does not
+// survive a JSON round trip, so it is not marshaled directly.
+type loggedToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+func marshalToolCalls(calls []llms.ToolCall) (string, error) {
+	logged := make([]loggedToolCall, 0, len(calls))
+	for _, call := range calls {
+		if call.FunctionCall == nil {
+			continue
+		}
+		logged = append(logged, loggedToolCall{
+			ID:        call.ID,
+			Name:      call.FunctionCall.Name,
+			Arguments: call.FunctionCall.Arguments,
+		})
+	}
+	b, err := json.Marshal(logged)
+	return string(b), err
+}
+
+func unmarshalToolCalls(log string) ([]llms.ToolCall, error) {
+	var logged []loggedToolCall
+	if err := json.Unmarshal([]byte(log), &logged); err != nil {
+		return nil, err
+	}
+	calls := make([]llms.ToolCall, 0, len(logged))
+	for _, l := range logged {
+		calls = append(calls, llms.ToolCall{
+			ID:   l.ID,
+			Type: "function",
+			FunctionCall: &llms.FunctionCall{
+				Name:      l.Name,
+				Arguments: l.Arguments,
+			},
+		})
+	}
+	return calls, nil
+}
+
+// toolCallsFromSteps rebuilds tool calls for steps whose log does not hold
+// the original assistant turn.
+func (a *FunctionCallingAgent) toolCallsFromSteps(steps []schema.AgentStep) []llms.ToolCall {
+	calls := make([]llms.ToolCall, 0, len(steps))
+	for _, step := range steps {
+		args, _ := json.Marshal(map[string]string{_functionCallingInputArg: step.Action.ToolInput})
+		if a.hasInputSchema(step.Action.Tool) && json.Valid([]byte(step.Action.ToolInput)) {
+			args = []byte(step.Action.ToolInput)
+		}
+		calls = append(calls, llms.ToolCall{
+			ID:   step.Action.ToolID,
+			Type: "function",
+			FunctionCall: &llms.FunctionCall{
+				Name:      step.Action.Tool,
+				Arguments: string(args),
+			},
+		})
+	}
+
+	return calls
+}
+
+// hasInputSchema reports whether the tool with the given name is a
+// SchemaTool, whose arguments are its input.
+func (a *FunctionCallingAgent) hasInputSchema(name string) bool {
+	for _, tool := range a.Tools {
+		if tool.Name() == name {
+			_, ok := tool.(SchemaTool)
+			return ok
+		}
+	}
+
+	return false
+}
+
+// functionCallInput extracts the tool input from the JSON arguments of a call.
+// Arguments without the input field, or meant for a tool with an input
+// schema, are passed to the tool as they are.
+func functionCallInput(arguments string, hasInputSchema bool) (string, error) {
+	if arguments == "" {
+		return "", nil
+	}
+	args := make(map[string]any)
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return "", err
+	}
+	if input, ok := args[_functionCallingInputArg].(string); ok && !hasInputSchema {
+		return input, nil
+	}
+
+	return arguments, nil
+}
+
+// createFunctionCallingPrompt renders the system message, the extra messages,
+// the conversation history under ChatHistoryKey and the input.
+func createFunctionCallingPrompt(opts agentOptions) prompts.ChatPromptTemplate {
+	messageFormatters := []prompts.MessageFormatter{prompts.NewSystemMessagePromptTemplate(opts.systemMessage, nil)}
+	messageFormatters = append(messageFormatters, opts.extraMessages...)
+	messageFormatters = append(messageFormatters, chatHistoryPlaceholder{})
+	messageFormatters = append(messageFormatters, prompts.NewHumanMessagePromptTemplate("{{.input}}", []string{"input"}))
+
+	return prompts.NewChatPromptTemplate(messageFormatters)
+}
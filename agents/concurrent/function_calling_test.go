@@ -0,0 +1,206 @@
+package concurrent
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tmc/langchaingo/agents"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/tools"
+)
+
+func toolCall(id, name, arguments string) llms.ToolCall {
+	return llms.ToolCall{ID: id, Type: "function", FunctionCall: &llms.FunctionCall{Name: name, Arguments: arguments}}
+}
+
+func toolCallReply(calls ...llms.ToolCall) *llms.ContentResponse {
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{ToolCalls: calls}}}
+}
+
+// functionCallingTools returns a plain tool and a SchemaTool.
+func functionCallingTools() []tools.Tool {
+	return []tools.Tool{
+		newFakeTool("search", nil),
+		schemaTool{fakeTool: newFakeTool("weather", nil), inputSchema: _citySchema},
+	}
+}
+
+func TestFunctionCallInput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		arguments string
+		schema    bool
+		want      string
+		wantErr   bool
+	}{
+		{arguments: "", want: ""},
+		{arguments: `{"input": "paris"}`, want: "paris"},
+		{arguments: `{"input": "paris"}`, schema: true, want: `{"input": "paris"}`},
+		{arguments: `{"city": "paris"}`, want: `{"city": "paris"}`},
+		{arguments: `{"city": "paris"}`, schema: true, want: `{"city": "paris"}`},
+		{arguments: `{"input": 3}`, want: `{"input": 3}`},
+		{arguments: `{"input": `, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := functionCallInput(tt.arguments, tt.schema)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("functionCallInput(%q, %v) = %q, %v; want %q (error %v)",
+				tt.arguments, tt.schema, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFunctionCallingAgentParseOutput(t *testing.T) {
+	t.Parallel()
+
+	agent := NewFunctionCallingAgent(nil, functionCallingTools())
+	calls := []llms.ToolCall{
+		toolCall("call_a", "search", `{"input": "paris"}`),
+		{ID: "call_x", Type: "function"},
+		toolCall("call_b", "weather", `{"city": "paris"}`),
+	}
+	planned, finish, err := agent.ParseOutput(toolCallReply(calls...))
+	if err != nil || finish != nil {
+		t.Fatalf("ParseOutput = %v, %v, want actions", finish, err)
+	}
+	turn, _ := marshalToolCalls(calls)
+	want := []schema.AgentAction{
+		{Tool: "search", ToolInput: "paris", Log: turn, ToolID: "call_a"},
+		{Tool: "weather", ToolInput: `{"city": "paris"}`, Log: turn, ToolID: "call_b"},
+	}
+	if !slices.Equal(planned, want) {
+		t.Errorf("actions = %+v, want %+v", planned, want)
+	}
+
+	_, finish, err = agent.ParseOutput(textReply("Paris"))
+	if err != nil || finish == nil || finish.ReturnValues["output"] != "Paris" {
+		t.Errorf("ParseOutput of a text reply = %+v, %v; want a finish", finish, err)
+	}
+	if _, _, err := agent.ParseOutput(toolCallReply(toolCall("c", "search", "{"))); !errors.Is(err, agents.ErrUnableToParseOutput) {
+		t.Errorf("ParseOutput of bad arguments error = %v, want ErrUnableToParseOutput", err)
+	}
+	for _, resp := range []*llms.ContentResponse{nil, {}, toolCallReply(llms.ToolCall{ID: "c"})} {
+		if _, _, err := agent.ParseOutput(resp); !errors.Is(err, agents.ErrAgentNoReturn) {
+			t.Errorf("ParseOutput(%v) error = %v, want ErrAgentNoReturn", resp, err)
+		}
+	}
+}
+
+func TestFunctionCallingAgentConstructToolMessages(t *testing.T) {
+	t.Parallel()
+
+	agent := NewFunctionCallingAgent(nil, functionCallingTools())
+	calls := []llms.ToolCall{toolCall("call_a", "search", `{"input": "paris"}`), toolCall("call_b", "weather", `{"city":"p"}`)}
+	turn, _ := marshalToolCalls(calls)
+	steps := []schema.AgentStep{
+		{Action: schema.AgentAction{Tool: "search", ToolInput: "paris", Log: turn, ToolID: "call_a"}, Observation: "found"},
+		{Action: schema.AgentAction{Tool: "weather", ToolInput: `{"city":"p"}`, Log: turn, ToolID: "call_b"}, Observation: "sunny"},
+		{Observation: "a note without action"},
+		// Steps restored from elsewhere lack the assistant turn in their log.
+		{Action: schema.AgentAction{Tool: "search", ToolInput: "lyon", Log: "plan 2", ToolID: "3"}, Observation: "found 2"},
+		{Action: schema.AgentAction{Tool: "weather", ToolInput: `{"city":"l"}`, Log: "plan 2", ToolID: "4"}, Observation: "rainy"},
+	}
+
+	messages := agent.constructToolMessages(steps)
+	response := func(id, name, content string) llms.MessageContent {
+		return llms.MessageContent{
+			Role:  llms.ChatMessageTypeTool,
+			Parts: []llms.ContentPart{llms.ToolCallResponse{ToolCallID: id, Name: name, Content: content}},
+		}
+	}
+	want := []llms.MessageContent{
+		{Role: llms.ChatMessageTypeAI, Parts: []llms.ContentPart{calls[0], calls[1]}},
+		response("call_a", "search", "found"),
+		response("call_b", "weather", "sunny"),
+		llms.TextParts(llms.ChatMessageTypeHuman, "a note without action"),
+		{Role: llms.ChatMessageTypeAI, Parts: []llms.ContentPart{
+			toolCall("3", "search", `{"input":"lyon"}`),
+			toolCall("4", "weather", `{"city":"l"}`),
+		}},
+		response("3", "search", "found 2"),
+		response("4", "weather", "rainy"),
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("messages = %+v, want %d", messages, len(want))
+	}
+	for i := range want {
+		if !messageEqual(messages[i], want[i]) {
+			t.Errorf("message %d = %+v, want %+v", i, messages[i], want[i])
+		}
+	}
+}
+
+// messageEqual compares messages made of text, tool call and tool response
+// parts.
+func messageEqual(a, b llms.MessageContent) bool {
+	return a.Role == b.Role && slices.EqualFunc(a.Parts, b.Parts, func(x, y llms.ContentPart) bool {
+		if x, ok := x.(llms.ToolCall); ok {
+			y, ok := y.(llms.ToolCall)
+			return ok && x.ID == y.ID && x.Type == y.Type && *x.FunctionCall == *y.FunctionCall
+		}
+		return x == y
+	})
+}
+
+func TestExecutorFunctionCallingParallelToolCalls(t *testing.T) {
+	t.Parallel()
+
+	// Each call waits for the other: they only finish if run in parallel.
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+	overlapping := func(name string) *fakeTool {
+		return newFakeTool(name, func(ctx context.Context, input string) (string, error) {
+			arrived.Done()
+			both := make(chan struct{})
+			go func() {
+				arrived.Wait()
+				close(both)
+			}()
+			select {
+			case <-both:
+				return name + " " + input, nil
+			case <-time.After(5 * time.Second):
+				return "", errors.New("the calls did not overlap")
+			}
+		})
+	}
+	weather := schemaTool{fakeTool: overlapping("weather"), inputSchema: _citySchema}
+	calls := []llms.ToolCall{toolCall("call_a", "search", `{"input": "paris"}`), toolCall("call_b", "weather", `{"city":"paris"}`)}
+	llm := &fakeLLM{replies: []*llms.ContentResponse{toolCallReply(calls...), textReply("sunny in paris")}}
+	agent := NewFunctionCallingAgent(llm, []tools.Tool{overlapping("search"), weather})
+	executor := NewExecutor(agent, Options{MaxIterations: 3})
+
+	out, err := executor.Call(context.Background(), map[string]any{"input": "weather in paris?"})
+	if err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if out["output"] != "sunny in paris" {
+		t.Errorf("output = %v, want the second reply", out["output"])
+	}
+
+	sent := llm.sent()
+	if len(sent) != 2 {
+		t.Fatalf("%d model calls, want 2", len(sent))
+	}
+	replayed := sent[1][len(sent[1])-3:]
+	want := []llms.MessageContent{
+		{Role: llms.ChatMessageTypeAI, Parts: []llms.ContentPart{calls[0], calls[1]}},
+		{Role: llms.ChatMessageTypeTool, Parts: []llms.ContentPart{llms.ToolCallResponse{
+			ToolCallID: "call_a", Name: "search", Content: "search paris",
+		}}},
+		{Role: llms.ChatMessageTypeTool, Parts: []llms.ContentPart{llms.ToolCallResponse{
+			ToolCallID: "call_b", Name: "weather", Content: `weather {"city":"paris"}`,
+		}}},
+	}
+	for i := range want {
+		if !messageEqual(replayed[i], want[i]) {
+			t.Errorf("replayed message %d = %+v, want %+v", i, replayed[i], want[i])
+		}
+	}
+}
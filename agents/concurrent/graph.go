@@ -0,0 +1,164 @@
+package concurrent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tmc/langchaingo/chains"
+	"github.com/tmc/langchaingo/schema"
+)
+
+var (
+	// ErrDependencyCycle is returned for a plan whose actions depend on each
+	// other in a cycle.
+	ErrDependencyCycle = errors.New("actions depend on each other in a cycle")
+	// ErrDependencyFailed is reported for an action that was skipped because
+	// an action it depends on did not succeed.
+	ErrDependencyFailed = errors.New("dependency did not succeed")
+)
+
+// _placeholder matches the ${id} references to the output of other actions
+// in an action input.
+var _placeholder = regexp.MustCompile(`\$\{([^${}\s]+)\}`)
+
+// ActionDependencies maps the ToolID of an action to the ToolIDs of the
+// actions of the same plan it waits for. The output of a dependency replaces
+// the ${id} placeholders in the input of the dependent action.
+type ActionDependencies map[string][]string
+
+// graphPlanner is implemented by agents whose plans can contain actions that
+// depend on other actions of the same plan.
+type graphPlanner interface {
+	PlanGraph(
+		ctx context.Context,
+		intermediateSteps []schema.AgentStep,
+		inputs map[string]string,
+		options ...chains.ChainCallOption,
+	) ([]schema.AgentAction, ActionDependencies, *schema.AgentFinish, error)
+}
+
+// checkDependencies rejects dependencies on unknown ids and cycles. ids lists
+// the actions in plan order.
+func checkDependencies(ids []string, deps map[string][]string) error {
+	known := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		known[id] = true
+	}
+	for _, id := range ids {
+		for _, dep := range deps[id] {
+			if !known[dep] {
+				return fmt.Errorf("%s depends on unknown action %s", id, dep)
+			}
+		}
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(ids))
+	var visit func(id string, path []string) error
+	visit = func(id string, path []string) error {
+		switch state[id] {
+		case visiting:
+			for i, p := range path {
+				if p == id {
+					path = path[i:]
+					break
+				}
+			}
+			return fmt.Errorf("%w: %s", ErrDependencyCycle, strings.Join(append(path, id), " -> "))
+		case visited:
+			return nil
+		}
+		state[id] = visiting
+		for _, dep := range deps[id] {
+			if err := visit(dep, append(path, id)); err != nil {
+				return err
+			}
+		}
+		state[id] = visited
+		return nil
+	}
+	for _, id := range ids {
+		if err := visit(id, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dependencyIndexes returns, for every action, the plan indexes of the
+// actions it depends on.
+func dependencyIndexes(actions []schema.AgentAction, deps ActionDependencies) ([][]int, error) {
+	if len(deps) == 0 {
+		return make([][]int, len(actions)), nil
+	}
+
+	ids := make([]string, len(actions))
+	index := make(map[string]int, len(actions))
+	for i, action := range actions {
+		if _, ok := index[action.ToolID]; ok || action.ToolID == "" {
+			return nil, fmt.Errorf("plan with dependencies needs unique action ids, got %q", action.ToolID)
+		}
+		ids[i] = action.ToolID
+		index[action.ToolID] = i
+	}
+	if err := checkDependencies(ids, deps); err != nil {
+		return nil, err
+	}
+
+	indexes := make([][]int, len(actions))
+	for i, action := range actions {
+		for _, dep := range deps[action.ToolID] {
+			indexes[i] = append(indexes[i], index[dep])
+		}
+	}
+
+	return indexes, nil
+}
+
+// substituteOutputs replaces the placeholders of the dependencies of action
+// with their observations. Other placeholders are left as they are.
+func substituteOutputs(action schema.AgentAction, dependencies []schema.AgentStep) schema.AgentAction {
+	if len(dependencies) == 0 || !strings.Contains(action.ToolInput, "${") {
+		return action
+	}
+
+	// In a JSON object input the placeholders stand inside strings, so the
+	// outputs are escaped to keep the input valid.
+	object := isJSONObject([]byte(action.ToolInput)) && json.Valid([]byte(action.ToolInput))
+	outputs := make(map[string]string, len(dependencies))
+	for _, step := range dependencies {
+		output := step.Observation
+		if object {
+			encoded, _ := json.Marshal(output)
+			output = string(encoded[1 : len(encoded)-1])
+		}
+		outputs[step.Action.ToolID] = output
+	}
+	input := _placeholder.ReplaceAllStringFunc(action.ToolInput, func(m string) string {
+		if output, ok := outputs[m[2:len(m)-1]]; ok {
+			return output
+		}
+		return m
+	})
+	if input == action.ToolInput {
+		return action
+	}
+
+	return editAction(action, input)
+}
+
+// skippedStep answers an action whose dependency did not succeed.
+func skippedStep(action schema.AgentAction, dependency schema.AgentAction) (schema.AgentStep, error) {
+	err := fmt.Errorf("%w: skipped because Action[%s] %s did not succeed",
+		ErrDependencyFailed, dependency.ToolID, dependency.Tool)
+
+	return schema.AgentStep{Action: action, Observation: err.Error()}, err
+}
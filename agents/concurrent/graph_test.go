@@ -0,0 +1,251 @@
+package concurrent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tmc/langchaingo/agents"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/tools"
+)
+
+func TestCheckDependencies(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		deps      map[string][]string
+		wantCycle bool
+		wantErr   string
+	}{
+		{name: "chain", deps: map[string][]string{"b": {"a"}, "c": {"a", "b"}}},
+		{name: "unknown", deps: map[string][]string{"b": {"x"}}, wantErr: "b depends on unknown action x"},
+		{name: "cycle", deps: map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"b"}}, wantCycle: true, wantErr: "b -> c -> b"},
+		{name: "self", deps: map[string][]string{"a": {"a"}}, wantCycle: true, wantErr: "a -> a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkDependencies([]string{"a", "b", "c"}, tt.deps)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("checkDependencies: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || errors.Is(err, ErrDependencyCycle) != tt.wantCycle {
+				t.Errorf("checkDependencies error = %v, want %q (cycle %v)", err, tt.wantErr, tt.wantCycle)
+			}
+		})
+	}
+}
+
+func TestDependencyIndexes(t *testing.T) {
+	t.Parallel()
+
+	planned := []schema.AgentAction{{ToolID: "1"}, {ToolID: "2"}, {ToolID: "3"}}
+	indexes, err := dependencyIndexes(planned, ActionDependencies{"3": {"1", "2"}})
+	if err != nil {
+		t.Fatalf("dependencyIndexes: %v", err)
+	}
+	if want := [][]int{nil, nil, {0, 1}}; !slices.EqualFunc(indexes, want, slices.Equal) {
+		t.Errorf("indexes = %v, want %v", indexes, want)
+	}
+
+	for _, ids := range [][]string{{"1", "1"}, {"1", ""}} {
+		planned := []schema.AgentAction{{ToolID: ids[0]}, {ToolID: ids[1]}}
+		if _, err := dependencyIndexes(planned, ActionDependencies{"1": nil}); err == nil {
+			t.Errorf("dependencyIndexes with ids %q succeeded, want an error", ids)
+		}
+	}
+	if indexes, err := dependencyIndexes(planned, nil); err != nil || len(indexes) != 3 {
+		t.Errorf("dependencyIndexes without dependencies = %v, %v", indexes, err)
+	}
+}
+
+func TestSubstituteOutputs(t *testing.T) {
+	t.Parallel()
+
+	output := "line 1\n\"quoted\""
+	dependencies := []schema.AgentStep{{Action: schema.AgentAction{ToolID: "1"}, Observation: output}}
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "text", input: "forecast for ${1} and ${9}", want: "forecast for " + output + " and ${9}"},
+		{name: "no placeholder", input: "plain", want: "plain"},
+		{name: "JSON object", input: `{"city":"${1}"}`, want: `{"city":"line 1\n\"quoted\""}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			action := schema.AgentAction{Tool: "weather", ToolInput: tt.input, ToolID: "2"}
+			action.Log = actionLog(action)
+			got := substituteOutputs(action, dependencies)
+			if got.ToolInput != tt.want {
+				t.Errorf("ToolInput = %q, want %q", got.ToolInput, tt.want)
+			}
+			if got.Log != actionLog(got) {
+				t.Errorf("Log = %q, want it in sync with the input", got.Log)
+			}
+		})
+	}
+
+	var object struct{ City string }
+	got := substituteOutputs(schema.AgentAction{ToolInput: `{"City":"${1}"}`}, dependencies)
+	if err := json.Unmarshal([]byte(got.ToolInput), &object); err != nil || object.City != output {
+		t.Errorf("substituted object %s decodes to %q, %v; want the output", got.ToolInput, object.City, err)
+	}
+}
+
+func TestConcurrentAgentParseGraph(t *testing.T) {
+	t.Parallel()
+
+	agent := NewConcurrentAgent(nil, nil)
+	output := `{"Actions": [
+		{"ID": "s", "Action": "search", "ActionInput": "paris"},
+		{"Action": "weather", "ActionInput": "${s} and ${other}"},
+		{"ID": "n", "Action": "notify", "ActionInput": "x", "DependsOn": ["s"]}
+	]}`
+	planned, deps, _, err := agent.parseOutput(output, 2)
+	if err != nil {
+		t.Fatalf("parseOutput: %v", err)
+	}
+	ids := make([]string, 0, len(planned))
+	for _, action := range planned {
+		ids = append(ids, action.ToolID)
+	}
+	if want := []string{"3", "4", "5"}; !slices.Equal(ids, want) {
+		t.Errorf("ToolIDs = %q, want %q", ids, want)
+	}
+	if want := (ActionDependencies{"4": {"3"}, "5": {"3"}}); !maps.EqualFunc(deps, want, slices.Equal) {
+		t.Errorf("deps = %v, want %v", deps, want)
+	}
+	if got := planned[1].ToolInput; got != "${3} and ${other}" {
+		t.Errorf("rewritten input = %q, want the placeholder of Action[3]", got)
+	}
+
+	for _, bad := range []string{
+		`{"Actions": [{"ID": "a", "Action": "x"}, {"ID": "a", "Action": "y"}]}`,
+		`{"Actions": [{"Action": "x", "DependsOn": ["missing"]}]}`,
+		`{"Actions": [{"ID": "a", "Action": "x", "DependsOn": ["b"]}, {"ID": "b", "Action": "y", "ActionInput": "${a}"}]}`,
+	} {
+		if _, _, _, err := agent.parseOutput(bad, 0); !errors.Is(err, agents.ErrUnableToParseOutput) {
+			t.Errorf("parseOutput(%s) error = %v, want ErrUnableToParseOutput", bad, err)
+		}
+	}
+}
+
+// graphExecutor runs a ConcurrentAgent answering plan, then "done".
+func graphExecutor(plan string, agentTools []tools.Tool, options Options) *Executor {
+	llm := &fakeLLM{replies: []*llms.ContentResponse{textReply(plan), textReply(`{"FinalAnswer": "done"}`)}}
+	options.MaxIterations = 3
+	options.ReturnIntermediateSteps = true
+
+	return NewExecutor(NewConcurrentAgent(llm, agentTools), options)
+}
+
+func TestExecutorDependencies(t *testing.T) {
+	t.Parallel()
+
+	const plan = `{"Actions": [
+		{"ID": "s", "Action": "search", "ActionInput": "paris"},
+		{"Action": "weather", "ActionInput": "${s}"}
+	]}`
+	tests := []struct {
+		name      string
+		search    func(ctx context.Context, input string) (string, error)
+		approver  Approver
+		wantObs   []string
+		wantCalls int32
+	}{
+		{
+			name:      "output substituted",
+			wantObs:   []string{"search: paris", "weather: search: paris"},
+			wantCalls: 1,
+		},
+		{
+			name:   "failed dependency",
+			search: func(context.Context, string) (string, error) { return "", errBoom },
+			wantObs: []string{
+				"search failed: boom",
+				"dependency did not succeed: skipped because Action[1] search did not succeed",
+			},
+		},
+		{
+			name: "rejected dependency",
+			approver: ApproverFunc(func(context.Context, string, []schema.AgentAction) ([]Decision, error) {
+				return []Decision{{Verdict: VerdictReject, Reason: "no"}, {}}, nil
+			}),
+			wantObs: []string{
+				"The action was rejected by a reviewer and did not run. Reason: no",
+				"dependency did not succeed: skipped because Action[1] search did not succeed",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			weather := newFakeTool("weather", nil)
+			executor := graphExecutor(plan, []tools.Tool{newFakeTool("search", tt.search), weather}, Options{
+				ErrorPolicy: ErrorPolicyCollectAll,
+				Approver:    tt.approver,
+			})
+
+			out, err := executor.Call(context.Background(), map[string]any{"input": "q"})
+			if err != nil {
+				t.Fatalf("Call: %v", err)
+			}
+			steps, _ := out[_intermediateStepsOutputKey].([]schema.AgentStep)
+			if got := observations(steps); !slices.Equal(got, tt.wantObs) {
+				t.Errorf("observations = %q, want %q", got, tt.wantObs)
+			}
+			if got := weather.calls.Load(); got != tt.wantCalls {
+				t.Errorf("weather calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestExecutorDependenciesFailFast(t *testing.T) {
+	t.Parallel()
+
+	var running, aborted atomic.Int32
+	slow := newFakeTool("slow", func(ctx context.Context, _ string) (string, error) {
+		running.Add(1)
+		defer running.Add(-1)
+		<-ctx.Done()
+		aborted.Add(1)
+		return "", ctx.Err()
+	})
+	fail := newFakeTool("fail", func(context.Context, string) (string, error) { return "", errBoom })
+	dependent := newFakeTool("dependent", nil)
+	executor := graphExecutor(`{"Actions": [
+		{"ID": "a", "Action": "slow", "ActionInput": "x"},
+		{"Action": "fail", "ActionInput": "x"},
+		{"Action": "dependent", "ActionInput": "${a}"}
+	]}`, []tools.Tool{slow, fail, dependent}, Options{ErrorPolicy: ErrorPolicyFailFast})
+
+	_, err := executor.Call(context.Background(), map[string]any{"input": "q"})
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("Call error = %v, want boom", err)
+	}
+	if got := running.Load(); got != 0 {
+		t.Errorf("%d actions still running after Call returned", got)
+	}
+	if aborted.Load() != 1 || dependent.calls.Load() != 0 {
+		t.Errorf("aborted = %d, dependent calls = %d; want the slow action cancelled and its dependent not run",
+			aborted.Load(), dependent.calls.Load())
+	}
+}
@@ -0,0 +1,286 @@
+package concurrent
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tmc/langchaingo/tools"
+)
+
+// SchemaTool is implemented by tools that declare the JSON Schema of their
+// input. The model can then write the input as a JSON object, which the
+// executor checks against the schema before calling the tool: violations
+// are returned to the model as the observation of the action. An object the
+// model wrapped in a fenced code block is unwrapped before both.
+//
+// The supported keywords are type, enum, const, properties, required,
+// additionalProperties, items, minItems, maxItems, minLength, maxLength,
+// pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum, anyOf and
+// oneOf. Other keywords are ignored.
+type SchemaTool interface {
+	tools.Tool
+	InputSchema() map[string]any
+}
+
+// validateToolInput checks a tool input against inputSchema and returns the
+// violations found.
+func validateToolInput(inputSchema map[string]any, input string) []string {
+	var value any
+	if err := json.Unmarshal([]byte(schemaToolInput(input)), &value); err != nil {
+		return []string{"the input is not valid JSON: " + err.Error()}
+	}
+
+	return validateValue(value, inputSchema, "input")
+}
+
+// schemaToolInput returns the input checked and passed to a SchemaTool: the
+// content of the fenced code block models sometimes wrap the object in, or
+// else the input unchanged.
+func schemaToolInput(input string) string {
+	trimmed := strings.TrimSpace(input)
+	if m := _fencedBlock.FindStringSubmatch(trimmed); m != nil && len(m[0]) == len(trimmed) {
+		return strings.TrimSpace(m[1])
+	}
+
+	return input
+}
+
+// invalidInputObservation tells the model why its input was refused and
+// what the tool expects.
+func invalidInputObservation(tool SchemaTool, violations []string) string {
+	expected, _ := json.Marshal(tool.InputSchema())
+
+	return fmt.Sprintf("The input of %s is invalid and the tool was not called:\n- %s\nWrite the \"ActionInput\" as a JSON object matching this schema: %s", //nolint:lll
+		tool.Name(), strings.Join(violations, "\n- "), expected)
+}
+
+//nolint:cyclop,gocognit,funlen
+func validateValue(value any, s map[string]any, path string) []string {
+	var violations []string
+	fail := func(format string, args ...any) {
+		violations = append(violations, path+" "+fmt.Sprintf(format, args...))
+	}
+
+	if types := stringList(s["type"]); len(types) > 0 && !slices.ContainsFunc(types, func(t string) bool {
+		return hasJSONType(value, t)
+	}) {
+		fail("must be of type %s, got %s", strings.Join(types, " or "), jsonType(value))
+		return violations
+	}
+	if enum, ok := s["enum"]; ok && !slices.ContainsFunc(anyList(enum), func(v any) bool { return jsonEqual(v, value) }) {
+		encoded, _ := json.Marshal(enum)
+		fail("must be one of %s", encoded)
+	}
+	if c, ok := s["const"]; ok && !jsonEqual(c, value) {
+		encoded, _ := json.Marshal(c)
+		fail("must be %s", encoded)
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		properties, _ := s["properties"].(map[string]any)
+		for _, name := range stringList(s["required"]) {
+			if _, ok := v[name]; !ok {
+				fail("is missing the required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := properties[name].(map[string]any); ok {
+				violations = append(violations, validateValue(v[name], property, path+"."+name)...)
+				continue
+			}
+			if _, ok := properties[name]; ok {
+				continue
+			}
+			switch additional := s["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					fail("has the unknown property %q", name)
+				}
+			case map[string]any:
+				violations = append(violations, validateValue(v[name], additional, path+"."+name)...)
+			}
+		}
+	case []any:
+		if items, ok := s["items"].(map[string]any); ok {
+			for i, item := range v {
+				violations = append(violations, validateValue(item, items, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+		if n, ok := number(s["minItems"]); ok && float64(len(v)) < n {
+			fail("must have at least %v items", n)
+		}
+		if n, ok := number(s["maxItems"]); ok && float64(len(v)) > n {
+			fail("must have at most %v items", n)
+		}
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if n, ok := number(s["minLength"]); ok && length < n {
+			fail("must be at least %v characters long", n)
+		}
+		if n, ok := number(s["maxLength"]); ok && length > n {
+			fail("must be at most %v characters long", n)
+		}
+		if pattern, ok := s["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				fail("must match the pattern %q", pattern)
+			}
+		}
+	case float64:
+		if n, ok := number(s["minimum"]); ok && v < n {
+			fail("must be at least %v", n)
+		}
+		if n, ok := number(s["maximum"]); ok && v > n {
+			fail("must be at most %v", n)
+		}
+		if n, ok := number(s["exclusiveMinimum"]); ok && v <= n {
+			fail("must be greater than %v", n)
+		}
+		if n, ok := number(s["exclusiveMaximum"]); ok && v >= n {
+			fail("must be less than %v", n)
+		}
+	}
+
+	if anyOf := schemaList(s["anyOf"]); len(anyOf) > 0 && !slices.ContainsFunc(anyOf, func(sub map[string]any) bool {
+		return len(validateValue(value, sub, path)) == 0
+	}) {
+		fail("must match at least one of the allowed schemas")
+	}
+	if oneOf := schemaList(s["oneOf"]); len(oneOf) > 0 {
+		matched := 0
+		for _, sub := range oneOf {
+			if len(validateValue(value, sub, path)) == 0 {
+				matched++
+			}
+		}
+		if matched != 1 {
+			fail("must match exactly one of the allowed schemas, matched %d", matched)
+		}
+	}
+
+	return violations
+}
+
+func hasJSONType(value any, t string) bool {
+	switch t {
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return jsonType(value) == t
+	}
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// jsonEqual compares a value of the schema, which may hold Go types, with a
+// decoded value.
+func jsonEqual(schemaValue, value any) bool {
+	encoded, err := json.Marshal(schemaValue)
+	if err != nil {
+		return false
+	}
+	var decoded any
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		return false
+	}
+	a, _ := json.Marshal(decoded)
+	b, _ := json.Marshal(value)
+
+	return string(a) == string(b)
+}
+
+// The helpers below read schema keywords written either as Go values or as
+// decoded JSON.
+
+func stringList(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	default:
+		return nil
+	}
+}
+
+func anyList(v any) []any {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var list []any
+	_ = json.Unmarshal(encoded, &list)
+
+	return list
+}
+
+func schemaList(v any) []map[string]any {
+	switch v := v.(type) {
+	case []map[string]any:
+		return v
+	case []any:
+		list := make([]map[string]any, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(map[string]any); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	default:
+		return nil
+	}
+}
+
+func number(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,222 @@
+package concurrent
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/tools"
+)
+
+// schemaTool is a fakeTool declaring an input schema.
+type schemaTool struct {
+	*fakeTool
+	inputSchema map[string]any
+}
+
+func (t schemaTool) InputSchema() map[string]any { return t.inputSchema }
+
+var _citySchema = map[string]any{
+	"type":       "object",
+	"properties": map[string]any{"city": map[string]any{"type": "string", "minLength": 1}},
+	"required":   []any{"city"},
+}
+
+func TestExecutorSchemaToolFencedInput(t *testing.T) {
+	t.Parallel()
+
+	var inputs []string
+	weather := schemaTool{
+		fakeTool: newFakeTool("weather", func(_ context.Context, input string) (string, error) {
+			inputs = append(inputs, input)
+			return "sunny", nil
+		}),
+		inputSchema: _citySchema,
+	}
+	agent := &scriptedAgent{
+		tools: []tools.Tool{weather},
+		plans: [][]schema.AgentAction{actions("weather", "```json\n{\"city\": \"paris\"}\n```")},
+	}
+	executor := NewExecutor(agent, Options{MaxIterations: 3, ReturnIntermediateSteps: true})
+
+	out, err := executor.Call(context.Background(), map[string]any{"input": "q"})
+	if err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	steps, _ := out[_intermediateStepsOutputKey].([]schema.AgentStep)
+	if got := observations(steps); !slices.Equal(got, []string{"sunny"}) {
+		t.Errorf("observations = %q, want the tool called", got)
+	}
+	if want := []string{`{"city": "paris"}`}; !slices.Equal(inputs, want) {
+		t.Errorf("tool inputs = %q, want %q", inputs, want)
+	}
+}
+
+func TestSchemaToolInput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`{"city": "paris"}`, `{"city": "paris"}`},
+		{"```json\n{\"city\": \"paris\"}\n```", `{"city": "paris"}`},
+		{" ```\n{}\n``` ", `{}`},
+		{"see ```json\n{}\n```", "see ```json\n{}\n```"},
+	}
+	for _, tt := range tests {
+		if got := schemaToolInput(tt.input); got != tt.want {
+			t.Errorf("schemaToolInput(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestValidateToolInput(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		schema map[string]any
+		input  string
+		want   []string
+	}{
+		{name: "not JSON", schema: _citySchema, input: "paris", want: []string{
+			"the input is not valid JSON: invalid character 'p' looking for beginning of value",
+		}},
+		{name: "type", schema: map[string]any{"type": "object"}, input: `[1]`, want: []string{
+			"input must be of type object, got array",
+		}},
+		{name: "type list", schema: map[string]any{"type": []any{"string", "null"}}, input: `null`},
+		{name: "integer", schema: map[string]any{"type": "integer"}, input: `1.5`, want: []string{
+			"input must be of type integer, got number",
+		}},
+		{name: "enum", schema: map[string]any{"enum": []string{"c", "f"}}, input: `"k"`, want: []string{
+			`input must be one of ["c","f"]`,
+		}},
+		{name: "const", schema: map[string]any{"const": 3}, input: `3`},
+		{name: "const mismatch", schema: map[string]any{"const": "x"}, input: `"y"`, want: []string{
+			`input must be "x"`,
+		}},
+		{name: "required and properties", schema: _citySchema, input: `{"city": 1}`, want: []string{
+			"input.city must be of type string, got number",
+		}},
+		{name: "missing required", schema: _citySchema, input: `{}`, want: []string{
+			`input is missing the required property "city"`,
+		}},
+		{
+			name:   "additionalProperties false",
+			schema: map[string]any{"properties": map[string]any{"a": map[string]any{}}, "additionalProperties": false},
+			input:  `{"a": 1, "b": 2}`,
+			want:   []string{`input has the unknown property "b"`},
+		},
+		{
+			name:   "additionalProperties schema",
+			schema: map[string]any{"additionalProperties": map[string]any{"type": "number"}},
+			input:  `{"a": 1, "b": "2"}`,
+			want:   []string{"input.b must be of type number, got string"},
+		},
+		{name: "items", schema: map[string]any{"items": map[string]any{"type": "string"}}, input: `["a", 2]`, want: []string{
+			"input[1] must be of type string, got number",
+		}},
+		{name: "minItems", schema: map[string]any{"minItems": 2}, input: `[1]`, want: []string{
+			"input must have at least 2 items",
+		}},
+		{name: "maxItems", schema: map[string]any{"maxItems": 1.0}, input: `[1, 2]`, want: []string{
+			"input must have at most 1 items",
+		}},
+		{name: "minLength", schema: map[string]any{"minLength": 3}, input: `"北京"`, want: []string{
+			"input must be at least 3 characters long",
+		}},
+		{name: "maxLength", schema: map[string]any{"maxLength": 2}, input: `"北京"`},
+		{name: "pattern", schema: map[string]any{"pattern": "^[0-9]+$"}, input: `"12a"`, want: []string{
+			`input must match the pattern "^[0-9]+$"`,
+		}},
+		{name: "minimum", schema: map[string]any{"minimum": -90}, input: `-91`, want: []string{
+			"input must be at least -90",
+		}},
+		{name: "maximum", schema: map[string]any{"maximum": 90}, input: `90`},
+		{name: "exclusiveMinimum", schema: map[string]any{"exclusiveMinimum": 0}, input: `0`, want: []string{
+			"input must be greater than 0",
+		}},
+		{name: "exclusiveMaximum", schema: map[string]any{"exclusiveMaximum": 10}, input: `10`, want: []string{
+			"input must be less than 10",
+		}},
+		{
+			name:   "anyOf",
+			schema: map[string]any{"anyOf": []any{map[string]any{"type": "string"}, map[string]any{"minimum": 5}}},
+			input:  `1`,
+			want:   []string{"input must match at least one of the allowed schemas"},
+		},
+		{
+			name:   "oneOf",
+			schema: map[string]any{"oneOf": []map[string]any{{"type": "number"}, {"minimum": 0}}},
+			input:  `1`,
+			want:   []string{"input must match exactly one of the allowed schemas, matched 2"},
+		},
+		{name: "unknown keyword", schema: map[string]any{"format": "email"}, input: `"x"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := validateToolInput(tt.schema, tt.input); !slices.Equal(got, tt.want) {
+				t.Errorf("violations = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestActionItemUnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		data string
+		want string
+	}{
+		{`{"Action": "weather", "ActionInput": {"city": "paris", "days": [1, 2]}}`, `{"city":"paris","days":[1,2]}`},
+		{`{"Action": "search", "ActionInput": "paris"}`, "paris"},
+		{`{"Action": "search", "ActionInput": null}`, ""},
+		{`{"Action": "search"}`, ""},
+	}
+	for _, tt := range tests {
+		var item ActionItem
+		if err := json.Unmarshal([]byte(tt.data), &item); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.data, err)
+			continue
+		}
+		if item.ActionInput != tt.want {
+			t.Errorf("Unmarshal(%s) ActionInput = %q, want %q", tt.data, item.ActionInput, tt.want)
+		}
+	}
+
+	var item ActionItem
+	if err := json.Unmarshal([]byte(`{"Action": "search", "ActionInput": 3}`), &item); err == nil {
+		t.Errorf("Unmarshal of a number input succeeded, want an error")
+	}
+}
+
+func TestExecutorSchemaToolViolations(t *testing.T) {
+	t.Parallel()
+
+	weather := schemaTool{fakeTool: newFakeTool("weather", nil), inputSchema: _citySchema}
+	agent := &scriptedAgent{
+		tools: []tools.Tool{weather},
+		plans: [][]schema.AgentAction{actions("weather", `{"town": "paris"}`)},
+	}
+	executor := NewExecutor(agent, Options{MaxIterations: 3, ReturnIntermediateSteps: true})
+
+	out, err := executor.Call(context.Background(), map[string]any{"input": "q"})
+	if err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	steps, _ := out[_intermediateStepsOutputKey].([]schema.AgentStep)
+	want := invalidInputObservation(weather, []string{`input is missing the required property "city"`})
+	if got := observations(steps); !slices.Equal(got, []string{want}) {
+		t.Errorf("observations = %q, want %q", got, want)
+	}
+	if got := weather.calls.Load(); got != 0 {
+		t.Errorf("weather calls = %d, want the tool not called", got)
+	}
+}
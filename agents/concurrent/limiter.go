@@ -0,0 +1,123 @@
+package concurrent
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+)
+
+// semaphore is a FIFO counting semaphore. Waiters are served in the order they
+// arrived and give up their place in the queue when their context is done.
+type semaphore struct {
+	size    int
+	cur     int
+	mu      sync.Mutex
+	waiters list.List
+}
+
+func newSemaphore(size int) *semaphore {
+	return &semaphore{size: size}
+}
+
+// Acquire blocks until a slot is available or ctx is done.
+func (s *semaphore) Acquire(ctx context.Context) error {
+	s.mu.Lock()
+	if s.cur < s.size && s.waiters.Len() == 0 {
+		s.cur++
+		s.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	elem := s.waiters.PushBack(ready)
+	s.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-ready:
+			// The slot was handed over while we were giving up, pass it on.
+			s.mu.Unlock()
+			s.Release()
+		default:
+			s.waiters.Remove(elem)
+			s.mu.Unlock()
+		}
+		return ctx.Err()
+	}
+}
+
+// Release frees a slot and hands it to the oldest waiter, if any.
+func (s *semaphore) Release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if front := s.waiters.Front(); front != nil {
+		s.waiters.Remove(front)
+		close(front.Value.(chan struct{})) //nolint:forcetypeassert
+		return
+	}
+	s.cur--
+}
+
+// actionLimiter bounds how many actions run at the same time, globally and
+// per tool.
+type actionLimiter struct {
+	global  *semaphore
+	perTool map[string]*semaphore
+}
+
+// newActionLimiter returns nil when no limit is configured. Non-positive
+// limits are ignored.
+func newActionLimiter(maxConcurrency int, toolConcurrency map[string]int) *actionLimiter {
+	l := &actionLimiter{}
+	if maxConcurrency > 0 {
+		l.global = newSemaphore(maxConcurrency)
+	}
+	for name, n := range toolConcurrency {
+		if n <= 0 {
+			continue
+		}
+		if l.perTool == nil {
+			l.perTool = make(map[string]*semaphore, len(toolConcurrency))
+		}
+		l.perTool[strings.ToUpper(name)] = newSemaphore(n)
+	}
+	if l.global == nil && l.perTool == nil {
+		return nil
+	}
+	return l
+}
+
+// acquire waits for a slot for the given tool. The per-tool slot is taken
+// first so that an action queued behind a busy tool does not hold a global
+// slot other tools could use.
+func (l *actionLimiter) acquire(ctx context.Context, tool string) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	toolSem := l.perTool[strings.ToUpper(tool)]
+	if toolSem != nil {
+		if err := toolSem.Acquire(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if l.global != nil {
+		if err := l.global.Acquire(ctx); err != nil {
+			if toolSem != nil {
+				toolSem.Release()
+			}
+			return nil, err
+		}
+	}
+	return func() {
+		if l.global != nil {
+			l.global.Release()
+		}
+		if toolSem != nil {
+			toolSem.Release()
+		}
+	}, nil
+}
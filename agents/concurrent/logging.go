@@ -0,0 +1,63 @@
+package concurrent
+
+import (
+	"context"
+	"log/slog"
+)
+
+const _runIDLogKey = "run_id"
+
+type activeRunKey struct{}
+
+// withActiveRun marks ctx as belonging to the run, for NewLogHandler.
+func withActiveRun(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, activeRunKey{}, runID)
+}
+
+func loggerOrDefault(logger *slog.Logger) *slog.Logger {
+	if logger != nil {
+		return logger
+	}
+
+	return slog.Default()
+}
+
+// NewLogHandler wraps h so that records logged with the context of an
+// Executor run, by the agent, the tools or the executor itself, carry the
+// run_id attribute.
+func NewLogHandler(h slog.Handler) slog.Handler {
+	return runIDHandler{h}
+}
+
+type runIDHandler struct {
+	slog.Handler
+}
+
+func (h runIDHandler) Handle(ctx context.Context, r slog.Record) error {
+	runID, ok := ctx.Value(activeRunKey{}).(string)
+	if ok {
+		r.Attrs(func(a slog.Attr) bool {
+			ok = a.Key != _runIDLogKey
+			return ok
+		})
+	}
+	if ok {
+		r = r.Clone()
+		r.AddAttrs(slog.String(_runIDLogKey, runID))
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h runIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return runIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h runIDHandler) WithGroup(name string) slog.Handler {
+	return runIDHandler{h.Handler.WithGroup(name)}
+}
+
+// log logs a record of the run, tagged with its id.
+func (r *runState) log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	r.logger.Log(ctx, level, msg, append([]any{slog.String(_runIDLogKey, r.runID)}, args...)...)
+}
@@ -0,0 +1,152 @@
+package concurrent
+
+import (
+	"context"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/prompts"
+)
+
+// ChatHistoryKey is the prompt variable the default prompts of
+// ConcurrentAgent and FunctionCallingAgent render the conversation history
+// into. Configure the memory of the executor with this key, e.g.
+// memory.NewConversationBuffer(memory.WithMemoryKey(concurrent.ChatHistoryKey)).
+const ChatHistoryKey = "chat_history"
+
+const (
+	_humanPrefix = "Human: "
+	_aiPrefix    = "AI: "
+)
+
+// loadMemory merges the memory variables into the inputs. It reports false,
+// leaving the inputs untouched, when they already hold every memory variable:
+// the run was started through chains.Call, which loads and saves the memory
+// itself.
+func (e *Executor) loadMemory(ctx context.Context, inputValues map[string]any) (map[string]any, bool, error) {
+	if e.Memory == nil {
+		return inputValues, false, nil
+	}
+	memoryKeys := e.Memory.MemoryVariables(ctx)
+	if len(memoryKeys) == 0 {
+		return inputValues, false, nil
+	}
+	loaded := true
+	for _, key := range memoryKeys {
+		if _, ok := inputValues[key]; !ok {
+			loaded = false
+			break
+		}
+	}
+	if loaded {
+		return inputValues, false, nil
+	}
+
+	memoryValues, err := e.Memory.LoadMemoryVariables(ctx, inputValues)
+	if err != nil {
+		return nil, false, err
+	}
+	fullValues := make(map[string]any, len(inputValues)+len(memoryValues))
+	for key, value := range inputValues {
+		fullValues[key] = value
+	}
+	for key, value := range memoryValues {
+		fullValues[key] = value
+	}
+
+	return fullValues, true, nil
+}
+
+// saveMemory stores the exchange of a finished run. Only the agent output
+// keys are saved so that intermediate steps do not confuse the memory.
+func (e *Executor) saveMemory(ctx context.Context, inputs map[string]string, outputValues map[string]any) error {
+	inputValues := make(map[string]any, len(inputs))
+	for key, value := range inputs {
+		inputValues[key] = value
+	}
+	outputs := make(map[string]any, len(e.GetOutputKeys()))
+	for _, key := range e.GetOutputKeys() {
+		if value, ok := outputValues[key]; ok {
+			outputs[key] = value
+		}
+	}
+
+	return e.Memory.SaveContext(ctx, inputValues, outputs)
+}
+
+// memoryValueToString renders a memory variable as prompt text. Memories
+// configured to return messages are rendered as a Human/AI transcript.
+func memoryValueToString(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case []llms.ChatMessage:
+		buffer, err := llms.GetBufferString(v, "Human", "AI")
+		return buffer, err == nil
+	default:
+		return "", false
+	}
+}
+
+// chatHistoryPlaceholder is the message formatter of FunctionCallingAgent for
+// the conversation history. The history reaches the agent as the transcript
+// rendered by memoryValueToString, or by a string memory with the default
+// prefixes; it is turned back into human and AI messages.
+type chatHistoryPlaceholder struct{}
+
+var _ prompts.MessageFormatter = chatHistoryPlaceholder{}
+
+func (chatHistoryPlaceholder) FormatMessages(values map[string]any) ([]llms.ChatMessage, error) {
+	history, _ := values[ChatHistoryKey].(string)
+
+	return transcriptMessages(history), nil
+}
+
+func (chatHistoryPlaceholder) GetInputVariables() []string {
+	return []string{ChatHistoryKey}
+}
+
+// transcriptMessages splits a "Human: ...\nAI: ..." transcript into messages.
+// Lines without a prefix continue the current message; a transcript that
+// does not start with a prefix is returned as a single system message.
+func transcriptMessages(history string) []llms.ChatMessage {
+	history = strings.TrimSpace(history)
+	if history == "" {
+		return nil
+	}
+	if !strings.HasPrefix(history, _humanPrefix) && !strings.HasPrefix(history, _aiPrefix) {
+		return []llms.ChatMessage{llms.SystemChatMessage{Content: "Conversation so far:\n" + history}}
+	}
+
+	var (
+		messages []llms.ChatMessage
+		role     llms.ChatMessageType
+		content  []string
+	)
+	flush := func() {
+		if role == "" {
+			return
+		}
+		text := strings.Join(content, "\n")
+		if role == llms.ChatMessageTypeHuman {
+			messages = append(messages, llms.HumanChatMessage{Content: text})
+		} else {
+			messages = append(messages, llms.AIChatMessage{Content: text})
+		}
+	}
+	for _, line := range strings.Split(history, "\n") {
+		switch {
+		case strings.HasPrefix(line, _humanPrefix):
+			flush()
+			role, content = llms.ChatMessageTypeHuman, []string{strings.TrimPrefix(line, _humanPrefix)}
+		case strings.HasPrefix(line, _aiPrefix):
+			flush()
+			role, content = llms.ChatMessageTypeAI, []string{strings.TrimPrefix(line, _aiPrefix)}
+		default:
+			content = append(content, line)
+		}
+	}
+	flush()
+
+	return messages
+}
@@ -0,0 +1,112 @@
+package concurrent
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/memory"
+)
+
+// fakeLLM answers every call with the next of its replies, repeating the
+// last one, and records the messages it was sent.
+type fakeLLM struct {
+	replies []*llms.ContentResponse
+
+	mu      sync.Mutex
+	calls   [][]llms.MessageContent
+	options []llms.CallOptions
+}
+
+func textReply(text string) *llms.ContentResponse {
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: text}}}
+}
+
+func (m *fakeLLM) GenerateContent(
+	ctx context.Context,
+	messages []llms.MessageContent,
+	options ...llms.CallOption,
+) (*llms.ContentResponse, error) {
+	var opts llms.CallOptions
+	for _, opt := range options {
+		opt(&opts)
+	}
+	m.mu.Lock()
+	i := min(len(m.calls), len(m.replies)-1)
+	m.calls = append(m.calls, slices.Clone(messages))
+	m.options = append(m.options, opts)
+	m.mu.Unlock()
+
+	reply := m.replies[i]
+	if opts.StreamingFunc != nil && len(reply.Choices) > 0 {
+		if err := opts.StreamingFunc(ctx, []byte(reply.Choices[0].Content)); err != nil {
+			return nil, err
+		}
+	}
+
+	return reply, nil
+}
+
+func (m *fakeLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+func (m *fakeLLM) sent() [][]llms.MessageContent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.calls)
+}
+
+func (m *fakeLLM) callOptions() []llms.CallOptions {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.options)
+}
+
+func TestFunctionCallingAgentChatHistory(t *testing.T) {
+	t.Parallel()
+
+	llm := &fakeLLM{replies: []*llms.ContentResponse{textReply("Paris"), textReply("2.1 million")}}
+	agent := NewFunctionCallingAgent(llm, nil)
+	executor := NewExecutor(agent, Options{
+		MaxIterations: 2,
+		Memory:        memory.NewConversationBuffer(memory.WithMemoryKey(ChatHistoryKey)),
+	})
+	if keys := agent.GetInputKeys(); slices.Contains(keys, ChatHistoryKey) {
+		t.Errorf("GetInputKeys() = %q, want no %s", keys, ChatHistoryKey)
+	}
+
+	ctx := context.Background()
+	if _, err := executor.Call(ctx, map[string]any{"input": "What is the capital of France?"}); err != nil {
+		t.Fatalf("first Call: %v", err)
+	}
+	if _, err := executor.Call(ctx, map[string]any{"input": "How many people live there?"}); err != nil {
+		t.Fatalf("second Call: %v", err)
+	}
+
+	sent := llm.sent()
+	if got := len(sent[0]); got != 2 {
+		t.Errorf("first call sent %d messages, want the system message and the input", got)
+	}
+	want := []struct {
+		role llms.ChatMessageType
+		text string
+	}{
+		{llms.ChatMessageTypeSystem, _defaultFunctionCallingSystemMessage},
+		{llms.ChatMessageTypeHuman, "What is the capital of France?"},
+		{llms.ChatMessageTypeAI, "Paris"},
+		{llms.ChatMessageTypeHuman, "How many people live there?"},
+	}
+	second := sent[1]
+	if len(second) != len(want) {
+		t.Fatalf("second call sent %d messages, want %d: %+v", len(second), len(want), second)
+	}
+	for i, w := range want {
+		text, _ := second[i].Parts[0].(llms.TextContent)
+		if second[i].Role != w.role || text.Text != w.text {
+			t.Errorf("message %d = %s %q, want %s %q", i, second[i].Role, text.Text, w.role, w.text)
+		}
+	}
+}
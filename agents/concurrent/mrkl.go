@@ -1,14 +1,16 @@
 package concurrent
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/tmc/langchaingo/agents"
-	"github.com/tmc/langchaingo/prompts"
-	"strings"
+	"log/slog"
+	"slices"
 	"time"
 
+	"github.com/tmc/langchaingo/agents"
+
 	"github.com/tmc/langchaingo/callbacks"
 	"github.com/tmc/langchaingo/chains"
 	"github.com/tmc/langchaingo/llms"
@@ -21,9 +23,52 @@ const (
 	_defaultOutputKey  = "output"
 )
 
+const _finalAnswerInstruction = `
+You cannot call any more tools. Using only the observations above, give the best
+possible answer to the question now in "FinalAnswer", saying what remains uncertain.
+`
+
+var _defaultStopWords = []string{"\nObservation:", "\n\tObservation:"} //nolint:gochecknoglobals
+
 type ActionItem struct {
-	Action      string `json:"Action"`
-	ActionInput string `json:"ActionInput"`
+	// ID names the action so that other actions of the same plan can depend
+	// on it and use its output with a ${ID} placeholder in their input.
+	ID     string `json:"ID,omitempty"`
+	Action string `json:"Action"`
+	// ActionInput is the input of the tool. An input written as a JSON
+	// object is kept as its JSON text.
+	ActionInput string   `json:"ActionInput"`
+	DependsOn   []string `json:"DependsOn,omitempty"`
+}
+
+// UnmarshalJSON accepts an "ActionInput" written as a JSON object, for tools
+// declaring an input schema, and keeps it as compact JSON text.
+func (i *ActionItem) UnmarshalJSON(data []byte) error {
+	type item ActionItem
+	var decoded struct {
+		item
+		ActionInput json.RawMessage `json:"ActionInput"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*i = ActionItem(decoded.item)
+
+	input := bytes.TrimSpace(decoded.ActionInput)
+	switch {
+	case len(input) == 0 || string(input) == "null":
+		i.ActionInput = ""
+	case input[0] == '{':
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, input); err != nil {
+			return err
+		}
+		i.ActionInput = compact.String()
+	default:
+		return json.Unmarshal(input, &i.ActionInput)
+	}
+
+	return nil
 }
 
 type TaskFlow struct {
@@ -48,35 +93,69 @@ type ConcurrentAgent struct {
 	OutputKey string
 	// CallbacksHandler is the handler for callbacks.
 	CallbacksHandler callbacks.Handler
+	// Scratchpad renders the intermediate steps into the prompt.
+	Scratchpad ScratchpadFormatter
+	// Compactor, if set, keeps the scratchpad within a token budget.
+	Compactor ScratchpadCompactor
+	// Logger receives the raw model outputs at debug level, slog.Default()
+	// if nil.
+	Logger *slog.Logger
 }
 
 var _ agents.Agent = (*ConcurrentAgent)(nil)
 
-func getConcurrentPrompt(tools []tools.Tool) prompts.PromptTemplate {
+// AnswerStreamHandler is implemented by callbacks handlers that want the
+// streamed output of ConcurrentAgent split in two, instead of the raw chunks
+// of HandleStreamingFunc. HandleAnswerChunk gets the text of "FinalAnswer",
+// decoded, as the model writes it; HandleThinkingChunk gets the rest of the
+// output, such as the thought and the actions of a plan.
+type AnswerStreamHandler interface {
+	HandleAnswerChunk(ctx context.Context, chunk string)
+	HandleThinkingChunk(ctx context.Context, chunk []byte)
+}
 
-	return createConcurrentPrompt(
-		tools,
-		ConcurrentTemplateBase{_defaultMrklPrefix, []string{"today"}},
-		ConcurrentTemplateBase{_defaultMrklFormatInstructions, []string{}},
-		ConcurrentTemplateBase{_defaultMrklSuffix, []string{"agent_scratchpad", "input"}},
-	)
+// NewConcurrentAgent creates a new ConcurrentAgent with the given LLM model and
+// tools, using the default prompt. The langchaingo agent options are ignored.
+//
+// Deprecated: use NewConcurrentAgentWithOptions, which applies the
+// AgentOptions and reports an unusable prompt.
+func NewConcurrentAgent(llm llms.Model, tools []tools.Tool, _ ...agents.Option) *ConcurrentAgent {
+	agent, err := NewConcurrentAgentWithOptions(llm, tools)
+	if err != nil {
+		// The default prompt always uses the tool variables.
+		panic(err)
+	}
+
+	return agent
 }
 
-// NewConcurrentAgent creates a new ConcurrentAgent with the given LLM model, tools,
-// and options. It returns a pointer to the created agent. The opts parameter
-// represents the options for the agent.
-func NewConcurrentAgent(llm llms.Model, tools []tools.Tool, opts ...agents.Option) *ConcurrentAgent {
+// NewConcurrentAgentWithOptions creates a new ConcurrentAgent with the given
+// LLM model, tools, and options. It returns an error if the configured prompt
+// is not usable.
+func NewConcurrentAgentWithOptions(llm llms.Model, tools []tools.Tool, opts ...AgentOption) (*ConcurrentAgent, error) {
+	options := concurrentDefaultOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	prompt, err := options.getPrompt(tools)
+	if err != nil {
+		return nil, err
+	}
 
 	return &ConcurrentAgent{
 		Chain: chains.NewLLMChain(
-			llm,
-			getConcurrentPrompt(tools),
-			//chains.WithCallback(options.callbacksHandler),
+			usageModel{stopWordsModel{Model: llm, stopWords: _defaultStopWords}},
+			prompt,
+			chains.WithCallback(options.callbacksHandler),
 		),
-		Tools:     tools,
-		OutputKey: _defaultOutputKey,
-		//CallbacksHandler: options.callbacksHandler,
-	}
+		Tools:            tools,
+		OutputKey:        options.outputKey,
+		CallbacksHandler: options.callbacksHandler,
+		Scratchpad:       options.scratchpad,
+		Compactor:        options.compactor,
+		Logger:           options.logger,
+	}, nil
 }
 
 // Plan decides what action to take or returns the final result of the input.
@@ -85,35 +164,164 @@ func (a *ConcurrentAgent) Plan(
 	intermediateSteps []schema.AgentStep,
 	inputs map[string]string,
 ) ([]schema.AgentAction, *schema.AgentFinish, error) {
+	return a.PlanWithOptions(ctx, intermediateSteps, inputs)
+}
+
+// PlanGraph is PlanWithOptions also returning the dependencies between the
+// planned actions.
+func (a *ConcurrentAgent) PlanGraph(
+	ctx context.Context,
+	intermediateSteps []schema.AgentStep,
+	inputs map[string]string,
+	options ...chains.ChainCallOption,
+) ([]schema.AgentAction, ActionDependencies, *schema.AgentFinish, error) {
+	scratchpad, err := a.constructScratchPad(ctx, intermediateSteps)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var onAction func(index int, raw string)
+	if speculate := speculatorFromContext(ctx); speculate != nil {
+		onAction = func(index int, raw string) {
+			if action, ok := streamedAction(raw, len(intermediateSteps), index); ok {
+				speculate(action)
+			}
+		}
+	}
+	output, err := a.predict(ctx, scratchpad, inputs, options, onAction)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	loggerOrDefault(a.Logger).DebugContext(ctx, "plan output", slog.String("output", output))
+	return a.parseOutput(output, len(intermediateSteps))
+}
+
+// PlanWithOptions is Plan with chain call options, such as temperature, max
+// tokens, model, stop words or a callbacks handler, applied to the LLM call.
+// Stop words given here are merged with the agent's own. A handler given with
+// chains.WithCallback receives the streamed chunks like the CallbacksHandler
+// of the agent.
+//
+// Only the actions that do not depend on other actions are returned, since
+// the caller cannot order them; the model plans the others again once it has
+// seen the results. The Executor uses PlanGraph to run the whole plan.
+func (a *ConcurrentAgent) PlanWithOptions(
+	ctx context.Context,
+	intermediateSteps []schema.AgentStep,
+	inputs map[string]string,
+	options ...chains.ChainCallOption,
+) ([]schema.AgentAction, *schema.AgentFinish, error) {
+	actions, deps, finish, err := a.PlanGraph(ctx, intermediateSteps, inputs, options...)
+	if err != nil || len(deps) == 0 {
+		return actions, finish, err
+	}
+
+	independent := make([]schema.AgentAction, 0, len(actions))
+	for _, action := range actions {
+		if len(deps[action.ToolID]) == 0 {
+			independent = append(independent, action)
+		}
+	}
+
+	return independent, finish, nil
+}
+
+// FinalAnswer asks the model for its best answer given the steps taken so
+// far. The executor uses it when it stops early with EarlyStoppingGenerate.
+func (a *ConcurrentAgent) FinalAnswer(
+	ctx context.Context,
+	intermediateSteps []schema.AgentStep,
+	inputs map[string]string,
+	options ...chains.ChainCallOption,
+) (*schema.AgentFinish, error) {
+	scratchpad, err := a.constructScratchPad(ctx, intermediateSteps)
+	if err != nil {
+		return nil, err
+	}
+	output, err := a.predict(ctx, scratchpad+_finalAnswerInstruction, inputs, options, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	answer := output
+	if task, err := parseTaskFlow(output); err == nil {
+		switch {
+		case task.FinalAnswer != "":
+			answer = task.FinalAnswer
+		case task.Thought != "":
+			answer = task.Thought
+		}
+	}
+
+	return &schema.AgentFinish{
+		ReturnValues: map[string]any{
+			a.OutputKey: answer,
+		},
+		Log: output,
+	}, nil
+}
+
+// predict calls the chain. onAction, if set, gets the entries of "Actions"
+// as they are streamed.
+func (a *ConcurrentAgent) predict(
+	ctx context.Context,
+	scratchpad string,
+	inputs map[string]string,
+	options []chains.ChainCallOption,
+	onAction func(index int, raw string),
+) (string, error) {
 	fullInputs := make(map[string]any, len(inputs))
 	for key, value := range inputs {
 		fullInputs[key] = value
 	}
 
-	fullInputs["agent_scratchpad"] = constructConcurrentScratchPad(intermediateSteps)
+	fullInputs["agent_scratchpad"] = scratchpad
 	fullInputs["today"] = time.Now().Format("January 02, 2006")
+	if _, ok := fullInputs[ChatHistoryKey]; !ok {
+		fullInputs[ChatHistoryKey] = ""
+	}
 
-	var stream func(ctx context.Context, chunk []byte) error
-
-	if a.CallbacksHandler != nil {
-		stream = func(ctx context.Context, chunk []byte) error {
-			a.CallbacksHandler.HandleStreamingFunc(ctx, chunk)
-			return nil
+	// The handler of the options gets the chunks like the agent's own, and
+	// the streaming function of the options still gets them too.
+	handlers := distinctHandlers(a.CallbacksHandler, callOptionsHandler(options))
+	var answerHandlers []AnswerStreamHandler
+	for _, h := range handlers {
+		if split, ok := h.(AnswerStreamHandler); ok {
+			answerHandlers = append(answerHandlers, split)
+		}
+	}
+	taskFlow := &taskFlowStream{onAction: onAction}
+	if len(answerHandlers) > 0 {
+		taskFlow.onAnswer = func(text string) {
+			for _, split := range answerHandlers {
+				split.HandleAnswerChunk(ctx, text)
+			}
+		}
+		taskFlow.onThinking = func(chunk []byte) {
+			for _, split := range answerHandlers {
+				split.HandleThinkingChunk(ctx, chunk)
+			}
 		}
 	}
+	var split func(chunk []byte)
+	if onAction != nil || len(answerHandlers) > 0 {
+		split = taskFlow.write
+	}
+	var llmOptions llms.CallOptions
+	for _, opt := range llmCallOptions(options) {
+		opt(&llmOptions)
+	}
+
+	callOptions := make([]chains.ChainCallOption, 0, len(options)+2) //nolint:mnd
+	callOptions = append(callOptions, chains.WithStopWords(_defaultStopWords))
+	callOptions = append(callOptions, options...)
+	callOptions = append(callOptions, chains.WithStreamingFunc(streamFunc(handlers, llmOptions.StreamingFunc, split)))
 
-	output, err := chains.Predict(
+	return chains.Predict(
 		ctx,
 		a.Chain,
 		fullInputs,
-		chains.WithStopWords([]string{"\nObservation:", "\n\tObservation:"}),
-		chains.WithStreamingFunc(stream),
+		callOptions...,
 	)
-	if err != nil {
-		return nil, nil, err
-	}
-	fmt.Println("@@@@output:", output)
-	return a.parseOutput(output)
 }
 
 func (a *ConcurrentAgent) GetInputKeys() []string {
@@ -122,7 +330,7 @@ func (a *ConcurrentAgent) GetInputKeys() []string {
 	// Remove inputs given in plan.
 	agentInput := make([]string, 0, len(chainInputs))
 	for _, v := range chainInputs {
-		if v == "agent_scratchpad" || v == "today" {
+		if v == "agent_scratchpad" || v == "today" || v == ChatHistoryKey {
 			continue
 		}
 		agentInput = append(agentInput, v)
@@ -139,28 +347,36 @@ func (a *ConcurrentAgent) GetTools() []tools.Tool {
 	return a.Tools
 }
 
-func constructConcurrentScratchPad(steps []schema.AgentStep) string {
-	var scratchPad string
-	if len(steps) > 0 {
-		for _, step := range steps {
-			scratchPad += "\n" + step.Action.Log
-			scratchPad += "\nObservation: " + step.Observation + "\n"
+// constructScratchPad renders the steps, compacted by the Compactor if set.
+func (a *ConcurrentAgent) constructScratchPad(ctx context.Context, steps []schema.AgentStep) (string, error) {
+	format := a.Scratchpad
+	if format == nil {
+		format = TextScratchpad
+	}
+	if a.Compactor != nil {
+		var err error
+		if steps, err = a.Compactor.Compact(ctx, steps, format); err != nil {
+			return "", err
 		}
 	}
 
-	return scratchPad
+	return format(steps), nil
 }
 
-func (a *ConcurrentAgent) parseOutput(output string) ([]schema.AgentAction, *schema.AgentFinish, error) {
-	output = strings.Replace(output, "`", "", -1)
-	output = strings.Replace(output, "json", "", 1)
-	output = strings.TrimSpace(output)
-	var task TaskFlow
-	if err := json.Unmarshal([]byte(output), &task); err != nil {
-		return nil, nil, fmt.Errorf("%s: %s", err.Error(), output)
+// parseOutput parses the TaskFlow in output. priorSteps is the number of steps
+// taken before this plan and is used to give every action a run-unique id.
+// The IDs chosen by the model are replaced by these ids in the dependencies
+// and placeholders of the returned actions.
+func (a *ConcurrentAgent) parseOutput(
+	output string,
+	priorSteps int,
+) ([]schema.AgentAction, ActionDependencies, *schema.AgentFinish, error) {
+	task, err := parseTaskFlow(output)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 	if task.FinalAnswer != "" {
-		return nil, &schema.AgentFinish{
+		return nil, nil, &schema.AgentFinish{
 			ReturnValues: map[string]any{
 				a.OutputKey: task.FinalAnswer,
 			},
@@ -168,16 +384,107 @@ func (a *ConcurrentAgent) parseOutput(output string) ([]schema.AgentAction, *sch
 		}, nil
 	}
 
-	actions := make([]schema.AgentAction, 0)
-	for _, action := range task.Actions {
-		actions = append(actions, schema.AgentAction{
-			Tool:      action.Action,
-			ToolInput: action.ActionInput,
-		})
+	toolIDs, err := taskFlowGraph(task.Actions, priorSteps)
+	if err != nil {
+		return nil, nil, nil, parseError(err)
+	}
+
+	actions := make([]schema.AgentAction, 0, len(task.Actions))
+	var deps ActionDependencies
+	for i, item := range task.Actions {
+		action := schema.AgentAction{
+			Tool:      item.Action,
+			ToolInput: item.ActionInput,
+			ToolID:    actionID(priorSteps, i),
+		}
+		for _, dep := range itemDependencies(item, toolIDs) {
+			if deps == nil {
+				deps = make(ActionDependencies)
+			}
+			deps[action.ToolID] = append(deps[action.ToolID], toolIDs[dep])
+		}
+		if len(deps[action.ToolID]) > 0 {
+			action.ToolInput = _placeholder.ReplaceAllStringFunc(action.ToolInput, func(m string) string {
+				if id, ok := toolIDs[m[2:len(m)-1]]; ok {
+					return "${" + id + "}"
+				}
+				return m
+			})
+		}
+		action.Log = actionLog(action)
+		actions = append(actions, action)
+	}
+
+	return actions, deps, nil, nil
+}
+
+// taskFlowGraph checks the IDs and dependencies of the actions of a TaskFlow
+// and maps each ID to the run-unique id of its action.
+func taskFlowGraph(items []ActionItem, priorSteps int) (map[string]string, error) {
+	toolIDs := make(map[string]string)
+	for i, item := range items {
+		if item.ID == "" {
+			continue
+		}
+		if _, ok := toolIDs[item.ID]; ok {
+			return nil, fmt.Errorf(`"ID" %q is used by more than one action`, item.ID)
+		}
+		toolIDs[item.ID] = actionID(priorSteps, i)
+	}
+
+	ids := make([]string, 0, len(items))
+	deps := make(map[string][]string)
+	for i, item := range items {
+		id := item.ID
+		if id == "" {
+			id = fmt.Sprintf(`"Actions"[%d]`, i)
+		}
+		ids = append(ids, id)
+		for _, dep := range item.DependsOn {
+			if _, ok := toolIDs[dep]; !ok {
+				return nil, fmt.Errorf(`%s depends on %q, which is not the "ID" of an action of this plan`, id, dep)
+			}
+		}
+		deps[id] = itemDependencies(item, toolIDs)
 	}
-	if len(actions) == 0 {
-		return nil, nil, fmt.Errorf("%w: %s", agents.ErrUnableToParseOutput, output)
+
+	return toolIDs, checkDependencies(ids, deps)
+}
+
+// itemDependencies returns the IDs an action waits for: those listed in
+// DependsOn and those whose output it uses through a placeholder.
+func itemDependencies(item ActionItem, toolIDs map[string]string) []string {
+	deps := slices.Clone(item.DependsOn)
+	for _, m := range _placeholder.FindAllStringSubmatch(item.ActionInput, -1) {
+		if _, ok := toolIDs[m[1]]; ok && !slices.Contains(deps, m[1]) {
+			deps = append(deps, m[1])
+		}
 	}
 
-	return actions, nil, nil
+	return deps
+}
+
+// stopWordsModel adds its stop words to those of every call. Chain call
+// options replace the stop words instead of adding to them, so the agent's
+// own stop words are merged in at the model level.
+type stopWordsModel struct {
+	llms.Model
+	stopWords []string
+}
+
+func (m stopWordsModel) GenerateContent(
+	ctx context.Context,
+	messages []llms.MessageContent,
+	options ...llms.CallOption,
+) (*llms.ContentResponse, error) {
+	options = append(options, func(o *llms.CallOptions) {
+		o.StopWords = slices.Clip(o.StopWords)
+		for _, word := range m.stopWords {
+			if !slices.Contains(o.StopWords, word) {
+				o.StopWords = append(o.StopWords, word)
+			}
+		}
+	})
+
+	return m.Model.GenerateContent(ctx, messages, options...)
 }
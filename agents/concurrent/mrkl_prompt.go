@@ -1,9 +1,10 @@
 package concurrent
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"maps"
 	"regexp"
 	"strings"
 
@@ -21,24 +22,27 @@ Answer the following questions to the best of your ability. You can use the foll
 	Generate a JSON-formatted data structure based on the information provided below.Output must strictly adhere to the standard JSON structure without any additional characters or strings.
 Content requirements:
 	•	If a task has actions that can be executed in parallel, then the “actions” field of that task can contain multiple actions.
-	•	If the actions of a task cannot be executed in parallel, then the “actions” field of that task must contain only one action.
+	•	If an action needs the result of another action, give that action an “ID” and list it in the “DependsOn” field of the action that needs it. Write ${ID} in the “ActionInput” where its result must be inserted. Actions run as soon as the actions they depend on are done, so dependent actions can be planned in the same task.
+	•	The “ActionInput” is a string, except for tools whose description gives an input schema: write their “ActionInput” as a JSON object matching the schema, not as a string.
 Output example:
 {
 	"Question": "the input question you must answer",
 	"Thought": "You should always be thinking about what needs to be done and what can be executed concurrently",
 	"FinalAnswer": "the final answer to the original input question,it must be a empty string if not end",
 	"Actions": [{
+		"ID": "a short unique name for the action, optional",
 		"Action": "the actions to take, should be in the [ {{.tool_names}} ]",
 		"ActionInput": "the input to the action"
 	}, {
 		"Action": "the actions to take, should be in the [ {{.tool_names}} ]",
-		"ActionInput": "the input to the action"
+		"ActionInput": "the input to the action, may contain ${ID} to use the result of the action with that ID",
+		"DependsOn": ["the IDs of the actions that must be done first, optional"]
 	}]
 }
 `
 
 	_defaultMrklSuffix = `Begin!
-
+{{.chat_history}}
 Question: {{.input}}
 {{.agent_scratchpad}}`
 )
@@ -48,7 +52,7 @@ type ConcurrentTemplateBase struct {
 	InputVariables []string
 }
 
-func createConcurrentPrompt(tools []tools.Tool, prefix, instructions, suffix ConcurrentTemplateBase) prompts.PromptTemplate {
+func createConcurrentPrompt(tools []tools.Tool, prefix, instructions, suffix ConcurrentTemplateBase) (prompts.PromptTemplate, error) {
 	template := strings.Join([]string{prefix.Template, instructions.Template, suffix.Template}, "\n\n")
 	inputVariables := make([]string, 0, len(prefix.InputVariables)+
 		len(instructions.InputVariables)+
@@ -57,24 +61,66 @@ func createConcurrentPrompt(tools []tools.Tool, prefix, instructions, suffix Con
 	inputVariables = append(inputVariables, instructions.InputVariables...)
 	inputVariables = append(inputVariables, suffix.InputVariables...)
 
-	if err := checkConcurrentTemplate(template); err != nil {
-		log.Println(err.Error())
+	if err := checkConcurrentTemplate(template, prompts.TemplateFormatGoTemplate); err != nil {
+		return prompts.PromptTemplate{}, err
 	}
 
-	return prompts.PromptTemplate{
+	return withToolVariables(prompts.PromptTemplate{
 		Template:       template,
 		TemplateFormat: prompts.TemplateFormatGoTemplate,
 		InputVariables: inputVariables,
-		PartialVariables: map[string]any{
-			"tool_names":        toolNames(tools),
-			"tool_descriptions": toolDescriptions(tools),
-		},
+	}, tools), nil
+}
+
+// withToolVariables returns prompt with the tool_names and tool_descriptions
+// partial variables of tools, unless prompt already sets them.
+func withToolVariables(prompt prompts.PromptTemplate, tools []tools.Tool) prompts.PromptTemplate {
+	partials := make(map[string]any, len(prompt.PartialVariables)+2) //nolint:mnd
+	maps.Copy(partials, prompt.PartialVariables)
+	if _, ok := partials["tool_names"]; !ok {
+		partials["tool_names"] = toolNames(tools)
+	}
+	if _, ok := partials["tool_descriptions"]; !ok {
+		partials["tool_descriptions"] = toolDescriptions(tools)
+	}
+	prompt.PartialVariables = partials
+
+	return prompt
+}
+
+// createConcurrentChatPrompt wraps prompt into a chat prompt made of the system
+// message, the extra messages and prompt itself as the human message.
+func createConcurrentChatPrompt(
+	prompt prompts.PromptTemplate,
+	systemMessage string,
+	extraMessages []prompts.MessageFormatter,
+) prompts.ChatPromptTemplate {
+	messageFormatters := make([]prompts.MessageFormatter, 0, len(extraMessages)+2)
+	if systemMessage != "" {
+		messageFormatters = append(messageFormatters, prompts.NewSystemMessagePromptTemplate(systemMessage, nil))
 	}
+	messageFormatters = append(messageFormatters, extraMessages...)
+	messageFormatters = append(messageFormatters, prompts.HumanMessagePromptTemplate{Prompt: prompt})
+
+	tmpl := prompts.NewChatPromptTemplate(messageFormatters)
+	tmpl.PartialVariables = prompt.PartialVariables
+	return tmpl
 }
 
-// checkMrklPrompt check Prompt for PartialVariables.
-func checkConcurrentTemplate(template string) error {
-	re := regexp.MustCompile(`\{\{\.(.*?)\}\}`)
+// _templateVariables finds the variables used by a template of each format.
+var _templateVariables = map[prompts.TemplateFormat]*regexp.Regexp{ //nolint:gochecknoglobals
+	prompts.TemplateFormatGoTemplate: regexp.MustCompile(`\{\{\.(.*?)\}\}`),
+	prompts.TemplateFormatJinja2:     regexp.MustCompile(`\{\{-?\s*(\w+)`),
+	prompts.TemplateFormatFString:    regexp.MustCompile(`\{(\w+)\}`),
+}
+
+// checkConcurrentTemplate checks that the template, written in format, uses
+// the tool_names and tool_descriptions partial variables.
+func checkConcurrentTemplate(template string, format prompts.TemplateFormat) error {
+	re, ok := _templateVariables[format]
+	if !ok {
+		return fmt.Errorf("%w: %s", prompts.ErrInvalidTemplateFormat, format)
+	}
 	matches := re.FindAllStringSubmatch(template, -1)
 	matchesMap := make(map[string]struct{})
 	for _, match := range matches {
@@ -101,10 +147,17 @@ func toolNames(tools []tools.Tool) string {
 	return tn.String()
 }
 
+// toolDescriptions lists the tools with their descriptions and, for a
+// SchemaTool, the schema of its input.
 func toolDescriptions(tools []tools.Tool) string {
 	var ts strings.Builder
 	for _, tool := range tools {
 		ts.WriteString(fmt.Sprintf("- %s: %s\n", tool.Name(), tool.Description()))
+		if schemaTool, ok := tool.(SchemaTool); ok {
+			if inputSchema, err := json.Marshal(schemaTool.InputSchema()); err == nil {
+				ts.WriteString(fmt.Sprintf("  Input schema of %s: %s\n", tool.Name(), inputSchema))
+			}
+		}
 	}
 
 	return ts.String()
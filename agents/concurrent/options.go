@@ -0,0 +1,185 @@
+package concurrent
+
+import (
+	"log/slog"
+
+	"github.com/tmc/langchaingo/callbacks"
+	"github.com/tmc/langchaingo/prompts"
+	"github.com/tmc/langchaingo/tools"
+)
+
+type agentOptions struct {
+	prompt             prompts.PromptTemplate
+	promptPrefix       ConcurrentTemplateBase
+	formatInstructions ConcurrentTemplateBase
+	promptSuffix       ConcurrentTemplateBase
+	outputKey          string
+	callbacksHandler   callbacks.Handler
+	scratchpad         ScratchpadFormatter
+	compactor          ScratchpadCompactor
+	logger             *slog.Logger
+
+	// chat models
+	systemMessage string
+	extraMessages []prompts.MessageFormatter
+}
+
+// AgentOption is a function type that can be used to modify the creation of
+// a ConcurrentAgent.
+type AgentOption func(*agentOptions)
+
+func concurrentDefaultOptions() agentOptions {
+	return agentOptions{
+		promptPrefix:       ConcurrentTemplateBase{_defaultMrklPrefix, []string{"today"}},
+		formatInstructions: ConcurrentTemplateBase{_defaultMrklFormatInstructions, []string{}},
+		promptSuffix:       ConcurrentTemplateBase{_defaultMrklSuffix, []string{ChatHistoryKey, "agent_scratchpad", "input"}},
+		outputKey:          _defaultOutputKey,
+		scratchpad:         TextScratchpad,
+	}
+}
+
+// getPrompt returns the custom prompt if one was set, otherwise the prompt
+// built from the prefix, format instructions and suffix. Either must use the
+// tool_names and tool_descriptions variables, which are filled in from tools
+// unless the custom prompt sets them. When a system message or extra messages
+// are configured the result is a chat prompt.
+func (o agentOptions) getPrompt(tools []tools.Tool) (prompts.FormatPrompter, error) {
+	prompt := o.prompt
+	if prompt.Template == "" {
+		var err error
+		prompt, err = createConcurrentPrompt(tools, o.promptPrefix, o.formatInstructions, o.promptSuffix)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if err := checkConcurrentTemplate(prompt.Template, prompt.TemplateFormat); err != nil {
+			return nil, err
+		}
+		prompt = withToolVariables(prompt, tools)
+	}
+	if o.systemMessage == "" && len(o.extraMessages) == 0 {
+		return prompt, nil
+	}
+
+	return createConcurrentChatPrompt(prompt, o.systemMessage, o.extraMessages), nil
+}
+
+// WithOptions applies the agent related fields of an executor Options value:
+// the prompt fields, OutputKey, CallbacksHandler, Logger, SystemMessage and
+// ExtraMessages. Empty fields keep their defaults.
+func WithOptions(opts Options) AgentOption {
+	return func(o *agentOptions) {
+		if opts.Prompt.Template != "" {
+			o.prompt = opts.Prompt
+		}
+		if opts.PromptPrefix != "" {
+			o.promptPrefix = ConcurrentTemplateBase{opts.PromptPrefix, opts.PromptPrefixInputVariables}
+		}
+		if opts.FormatInstructions != "" {
+			o.formatInstructions = ConcurrentTemplateBase{opts.FormatInstructions, opts.FormatInstructionsInputVariables}
+		}
+		if opts.PromptSuffix != "" {
+			o.promptSuffix = ConcurrentTemplateBase{opts.PromptSuffix, opts.PromptSuffixInputVariables}
+		}
+		if opts.OutputKey != "" {
+			o.outputKey = opts.OutputKey
+		}
+		if opts.CallbacksHandler != nil {
+			o.callbacksHandler = opts.CallbacksHandler
+		}
+		if opts.Logger != nil {
+			o.logger = opts.Logger
+		}
+		if opts.SystemMessage != "" {
+			o.systemMessage = opts.SystemMessage
+		}
+		if len(opts.ExtraMessages) > 0 {
+			o.extraMessages = opts.ExtraMessages
+		}
+	}
+}
+
+// WithPrompt sets the prompt the agent will use. The prompt replaces the one
+// built from the prefix, format instructions and suffix, and must use the
+// tool_names and tool_descriptions variables too.
+func WithPrompt(prompt prompts.PromptTemplate) AgentOption {
+	return func(o *agentOptions) {
+		o.prompt = prompt
+	}
+}
+
+// WithPromptPrefix sets the prefix of the prompt and the input variables it uses.
+func WithPromptPrefix(prefix string, inputVariables ...string) AgentOption {
+	return func(o *agentOptions) {
+		o.promptPrefix = ConcurrentTemplateBase{prefix, inputVariables}
+	}
+}
+
+// WithPromptFormatInstructions sets the format instructions of the prompt and
+// the input variables they use.
+func WithPromptFormatInstructions(instructions string, inputVariables ...string) AgentOption {
+	return func(o *agentOptions) {
+		o.formatInstructions = ConcurrentTemplateBase{instructions, inputVariables}
+	}
+}
+
+// WithPromptSuffix sets the suffix of the prompt and the input variables it uses.
+func WithPromptSuffix(suffix string, inputVariables ...string) AgentOption {
+	return func(o *agentOptions) {
+		o.promptSuffix = ConcurrentTemplateBase{suffix, inputVariables}
+	}
+}
+
+// WithOutputKey sets the key where the final output is placed.
+func WithOutputKey(outputKey string) AgentOption {
+	return func(o *agentOptions) {
+		o.outputKey = outputKey
+	}
+}
+
+// WithCallbacksHandler sets the callbacks handler of the agent and its chain.
+func WithCallbacksHandler(handler callbacks.Handler) AgentOption {
+	return func(o *agentOptions) {
+		o.callbacksHandler = handler
+	}
+}
+
+// WithSystemMessage makes the agent use a chat prompt starting with the given
+// system message.
+func WithSystemMessage(msg string) AgentOption {
+	return func(o *agentOptions) {
+		o.systemMessage = msg
+	}
+}
+
+// WithExtraMessages makes the agent use a chat prompt with the given messages
+// placed between the system message and the main prompt.
+func WithExtraMessages(extraMessages []prompts.MessageFormatter) AgentOption {
+	return func(o *agentOptions) {
+		o.extraMessages = extraMessages
+	}
+}
+
+// WithScratchpadFormatter sets how intermediate steps are rendered into the
+// agent_scratchpad, e.g. TextScratchpad (the default) or JSONScratchpad.
+func WithScratchpadFormatter(f ScratchpadFormatter) AgentOption {
+	return func(o *agentOptions) {
+		o.scratchpad = f
+	}
+}
+
+// WithScratchpadCompactor keeps the agent_scratchpad within a token budget
+// with one of TruncateOldestCompactor, HeadTailCompactor or
+// SummarizingCompactor.
+func WithScratchpadCompactor(c ScratchpadCompactor) AgentOption {
+	return func(o *agentOptions) {
+		o.compactor = c
+	}
+}
+
+// WithLogger sets the logger of the agent, slog.Default() if not set.
+func WithLogger(logger *slog.Logger) AgentOption {
+	return func(o *agentOptions) {
+		o.logger = logger
+	}
+}
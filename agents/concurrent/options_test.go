@@ -0,0 +1,168 @@
+package concurrent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/prompts"
+	"github.com/tmc/langchaingo/tools"
+)
+
+func agentPrompt(t *testing.T, agentTools []tools.Tool, opts ...AgentOption) (prompts.FormatPrompter, error) {
+	t.Helper()
+
+	options := concurrentDefaultOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return options.getPrompt(agentTools)
+}
+
+func TestGetPromptErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		opt  AgentOption
+		want string
+	}{
+		{
+			name: "prefix without tool descriptions",
+			opt:  WithPromptPrefix("Answer {{.input}}."),
+			want: "tool_descriptions is not contained in option template",
+		},
+		{
+			name: "custom prompt without tool names",
+			opt: WithPrompt(prompts.NewPromptTemplate(
+				"{{.tool_descriptions}}\n{{.input}}\n{{.agent_scratchpad}}", []string{"input", "agent_scratchpad"})),
+			want: "tool_names is not contained in option template",
+		},
+		{
+			name: "custom f-string prompt without tool descriptions",
+			opt: WithOptions(Options{Prompt: prompts.PromptTemplate{
+				Template:       "{tool_names} {input}",
+				TemplateFormat: prompts.TemplateFormatFString,
+				InputVariables: []string{"input"},
+			}}),
+			want: "tool_descriptions is not contained in option template",
+		},
+		{
+			name: "custom prompt without format",
+			opt:  WithPrompt(prompts.PromptTemplate{Template: "{{.tool_names}} {{.tool_descriptions}}"}),
+			want: prompts.ErrInvalidTemplateFormat.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := agentPrompt(t, nil, tt.opt); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("getPrompt error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewConcurrentAgentWithOptions(nil, nil, WithPrompt(prompts.NewPromptTemplate("{{.input}}", nil))); err == nil {
+		t.Errorf("NewConcurrentAgentWithOptions with an unusable prompt succeeded")
+	}
+}
+
+func TestGetPromptCustomPrompt(t *testing.T) {
+	t.Parallel()
+
+	agentTools := []tools.Tool{newFakeTool("search", nil), newFakeTool("weather", nil)}
+	tests := []struct {
+		name   string
+		prompt prompts.PromptTemplate
+		want   string
+	}{
+		{
+			name: "go template",
+			prompt: prompts.NewPromptTemplate(
+				"Tools: {{.tool_names}}\n{{.tool_descriptions}}Q: {{.input}}", []string{"input"}),
+			want: "Tools: search, weather\n" + toolDescriptions(agentTools) + "Q: where?",
+		},
+		{
+			name: "f-string",
+			prompt: prompts.PromptTemplate{
+				Template:       "Tools: {tool_names}\n{tool_descriptions}Q: {input}",
+				TemplateFormat: prompts.TemplateFormatFString,
+				InputVariables: []string{"input"},
+			},
+			want: "Tools: search, weather\n" + toolDescriptions(agentTools) + "Q: where?",
+		},
+		{
+			name: "tool names set by the prompt",
+			prompt: prompts.PromptTemplate{
+				Template:         "Tools: {{.tool_names}}\n{{.tool_descriptions}}Q: {{.input}}",
+				TemplateFormat:   prompts.TemplateFormatGoTemplate,
+				InputVariables:   []string{"input"},
+				PartialVariables: map[string]any{"tool_names": "only search"},
+			},
+			want: "Tools: only search\n" + toolDescriptions(agentTools) + "Q: where?",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			prompt, err := agentPrompt(t, agentTools, WithPrompt(tt.prompt))
+			if err != nil {
+				t.Fatalf("getPrompt: %v", err)
+			}
+			value, err := prompt.FormatPrompt(map[string]any{"input": "where?"})
+			if err != nil {
+				t.Fatalf("FormatPrompt: %v", err)
+			}
+			if got := value.String(); got != tt.want {
+				t.Errorf("prompt = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPromptChat(t *testing.T) {
+	t.Parallel()
+
+	agentTools := []tools.Tool{newFakeTool("search", nil)}
+	custom := prompts.NewPromptTemplate("{{.tool_names}}|{{.tool_descriptions}}|{{.input}}", []string{"input"})
+	prompt, err := agentPrompt(t, agentTools,
+		WithPrompt(custom),
+		WithSystemMessage("You are terse."),
+		WithExtraMessages([]prompts.MessageFormatter{prompts.NewAIMessagePromptTemplate("Ready.", nil)}),
+	)
+	if err != nil {
+		t.Fatalf("getPrompt: %v", err)
+	}
+	if _, ok := prompt.(prompts.ChatPromptTemplate); !ok {
+		t.Fatalf("prompt is a %T, want a chat prompt", prompt)
+	}
+	value, err := prompt.FormatPrompt(map[string]any{"input": "where?"})
+	if err != nil {
+		t.Fatalf("FormatPrompt: %v", err)
+	}
+
+	messages := value.Messages()
+	want := []struct {
+		role llms.ChatMessageType
+		text string
+	}{
+		{llms.ChatMessageTypeSystem, "You are terse."},
+		{llms.ChatMessageTypeAI, "Ready."},
+		{llms.ChatMessageTypeHuman, "search|" + toolDescriptions(agentTools) + "|where?"},
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("messages = %v, want %d", messages, len(want))
+	}
+	for i, w := range want {
+		if messages[i].GetType() != w.role || messages[i].GetContent() != w.text {
+			t.Errorf("message %d = %s %q, want %s %q", i, messages[i].GetType(), messages[i].GetContent(), w.role, w.text)
+		}
+	}
+
+	if _, err := agentPrompt(t, agentTools, WithSystemMessage("x"), WithPromptPrefix("{{.input}}")); err == nil {
+		t.Errorf("chat prompt from an unusable prefix succeeded, want an error")
+	}
+}
@@ -0,0 +1,84 @@
+// Package otel exports the spans of a concurrent.Tracer to OpenTelemetry.
+// It is a package of its own so that the concurrent package does not depend
+// on OpenTelemetry.
+package otel
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/thinkdb1/langchaingo-ext/agents/concurrent"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Processor mirrors the spans as OpenTelemetry spans of tracer. A run span
+// becomes a child of the OpenTelemetry span in the context of the run.
+type Processor struct {
+	tracer trace.Tracer
+
+	mu    sync.Mutex
+	spans map[string]trace.Span
+}
+
+var _ concurrent.SpanProcessor = &Processor{}
+
+// NewProcessor creates a Processor, e.g. with
+// otel.Tracer("langchaingo-ext/concurrent") of go.opentelemetry.io/otel.
+func NewProcessor(tracer trace.Tracer) *Processor {
+	return &Processor{tracer: tracer, spans: make(map[string]trace.Span)}
+}
+
+func (p *Processor) OnStart(ctx context.Context, span concurrent.Span) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if parent, ok := p.spans[span.ParentID]; ok {
+		ctx = trace.ContextWithSpan(ctx, parent)
+	}
+	_, otelSpan := p.tracer.Start(ctx, span.Name,
+		trace.WithTimestamp(span.Start),
+		trace.WithAttributes(attribute.String("agent.span.kind", string(span.Kind))),
+	)
+	p.spans[span.SpanID] = otelSpan
+}
+
+func (p *Processor) OnEnd(_ context.Context, span concurrent.Span) {
+	p.mu.Lock()
+	otelSpan, ok := p.spans[span.SpanID]
+	delete(p.spans, span.SpanID)
+	p.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	attributes := make([]attribute.KeyValue, 0, len(span.Attributes))
+	for k, v := range span.Attributes {
+		attributes = append(attributes, otelAttribute("agent."+k, v))
+	}
+	otelSpan.SetAttributes(attributes...)
+	if span.Err != "" {
+		otelSpan.SetStatus(codes.Error, span.Err)
+	}
+	otelSpan.End(trace.WithTimestamp(span.End))
+}
+
+func otelAttribute(key string, value any) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case []string:
+		return attribute.StringSlice(key, v)
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,190 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thinkdb1/langchaingo-ext/agents/concurrent"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/tools"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recordedSpan is an OpenTelemetry span recording what the Processor sets.
+type recordedSpan struct {
+	noop.Span
+
+	name   string
+	parent *recordedSpan
+
+	mu         sync.Mutex
+	attributes map[attribute.Key]attribute.Value
+	status     codes.Code
+	ended      bool
+}
+
+func (s *recordedSpan) SetAttributes(kv ...attribute.KeyValue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range kv {
+		s.attributes[a.Key] = a.Value
+	}
+}
+
+func (s *recordedSpan) SetStatus(code codes.Code, _ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = code
+}
+
+func (s *recordedSpan) End(...trace.SpanEndOption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+}
+
+// recordingTracer is an OpenTelemetry tracer keeping its spans.
+type recordingTracer struct {
+	noop.Tracer
+
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) Start(
+	ctx context.Context,
+	name string,
+	opts ...trace.SpanStartOption,
+) (context.Context, trace.Span) {
+	parent, _ := trace.SpanFromContext(ctx).(*recordedSpan)
+	span := &recordedSpan{name: name, parent: parent, attributes: make(map[attribute.Key]attribute.Value)}
+	config := trace.NewSpanStartConfig(opts...)
+	span.SetAttributes(config.Attributes()...)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = append(t.spans, span)
+
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+// fakeTool returns its input, or fails on "fail".
+type fakeTool struct{}
+
+func (fakeTool) Name() string        { return "search" }
+func (fakeTool) Description() string { return "a fake tool" }
+
+func (fakeTool) Call(_ context.Context, input string) (string, error) {
+	if input == "fail" {
+		return "", errors.New("boom")
+	}
+	return "found " + input, nil
+}
+
+// planAgent plans one action per input, then finishes.
+type planAgent struct{ inputs []string }
+
+func (a planAgent) Plan(
+	_ context.Context,
+	steps []schema.AgentStep,
+	_ map[string]string,
+) ([]schema.AgentAction, *schema.AgentFinish, error) {
+	if len(steps) > 0 {
+		return nil, &schema.AgentFinish{ReturnValues: map[string]any{"output": "done"}}, nil
+	}
+	actions := make([]schema.AgentAction, 0, len(a.inputs))
+	for i, input := range a.inputs {
+		actions = append(actions, schema.AgentAction{Tool: "search", ToolInput: input, ToolID: string(rune('1' + i))})
+	}
+
+	return actions, nil, nil
+}
+
+func (planAgent) GetInputKeys() []string  { return []string{"input"} }
+func (planAgent) GetOutputKeys() []string { return []string{"output"} }
+func (planAgent) GetTools() []tools.Tool  { return []tools.Tool{fakeTool{}} }
+
+func TestProcessor(t *testing.T) {
+	t.Parallel()
+
+	otelTracer := &recordingTracer{}
+	executor := concurrent.NewExecutor(planAgent{inputs: []string{"a", "fail"}}, concurrent.Options{
+		MaxIterations: 3,
+		ErrorPolicy:   concurrent.ErrorPolicyCollectAll,
+		Tracer:        concurrent.NewTracer(NewProcessor(otelTracer)),
+	})
+
+	// The run span is a child of the span of the caller.
+	ctx, request := otelTracer.Start(context.Background(), "request")
+	if _, err := executor.Call(ctx, map[string]any{"input": "q"}); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+
+	otelTracer.mu.Lock()
+	defer otelTracer.mu.Unlock()
+	kinds := make(map[string]int)
+	failed := 0
+	for _, span := range otelTracer.spans[1:] {
+		span.mu.Lock()
+		kind := span.attributes["agent.span.kind"].AsString()
+		kinds[kind]++
+		if !span.ended {
+			t.Errorf("%s span %q not ended", kind, span.name)
+		}
+		var wantParent string
+		switch concurrent.SpanKind(kind) {
+		case concurrent.SpanRun:
+			if span.parent != request {
+				t.Errorf("run span parent = %v, want the request span", span.parent)
+			}
+		case concurrent.SpanIteration:
+			wantParent = string(concurrent.SpanRun)
+		case concurrent.SpanPlan, concurrent.SpanAction:
+			wantParent = string(concurrent.SpanIteration)
+		}
+		if wantParent != "" && (span.parent == nil || span.parent.attributes["agent.span.kind"].AsString() != wantParent) {
+			t.Errorf("%s span %q parent = %v, want a %s span", kind, span.name, span.parent, wantParent)
+		}
+		if span.status == codes.Error {
+			failed++
+		}
+		if kind == string(concurrent.SpanRun) && span.attributes["agent.run_id"].AsString() == "" {
+			t.Errorf("run span attributes = %v, want agent.run_id", span.attributes)
+		}
+		span.mu.Unlock()
+	}
+	if kinds[string(concurrent.SpanRun)] != 1 || kinds[string(concurrent.SpanAction)] != 2 {
+		t.Errorf("span kinds = %v, want one run and two actions", kinds)
+	}
+	if failed != 1 {
+		t.Errorf("%d spans with an error status, want the failed action only", failed)
+	}
+}
+
+func TestOtelAttribute(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value any
+		want  attribute.Value
+	}{
+		{"x", attribute.StringValue("x")},
+		{3, attribute.IntValue(3)},
+		{int64(4), attribute.Int64Value(4)},
+		{0.5, attribute.Float64Value(0.5)},
+		{true, attribute.BoolValue(true)},
+		{[]string{"a"}, attribute.StringSliceValue([]string{"a"})},
+		{time.Second, attribute.StringValue("1s")},
+	}
+	for _, tt := range tests {
+		if got := otelAttribute("k", tt.value); got.Value != tt.want {
+			t.Errorf("otelAttribute(%v) = %v, want %v", tt.value, got.Value.Emit(), tt.want.Emit())
+		}
+	}
+}
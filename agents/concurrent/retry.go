@@ -0,0 +1,183 @@
+package concurrent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const _actionAttemptsOutputKey = "actionAttempts"
+
+const (
+	_defaultInitialBackoff = 200 * time.Millisecond
+	_defaultMaxBackoff     = 5 * time.Second
+	_defaultBackoffFactor  = 2
+	_defaultBackoffJitter  = 0.2
+)
+
+// ActionPolicy configures the timeout and retries of the calls to a tool.
+type ActionPolicy struct {
+	// Timeout bounds each attempt. Zero means no timeout.
+	Timeout time.Duration
+	// Retry decides whether and when a failed attempt is retried.
+	Retry RetryPolicy
+}
+
+// RetryPolicy configures the retries of a failing action. Attempts are
+// spaced by an exponential backoff with jitter.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts. Zero or one disables
+	// retries.
+	MaxAttempts int
+	// InitialBackoff is the wait before the second attempt, 200ms if zero.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between attempts, 5s if zero.
+	MaxBackoff time.Duration
+	// Multiplier grows the backoff after each attempt, 2 if zero.
+	Multiplier float64
+	// Jitter randomizes each backoff by up to this fraction, 0.2 if zero.
+	// Use a negative value to disable it.
+	Jitter float64
+	// Retryable classifies errors worth retrying, IsTransientError if nil.
+	Retryable func(error) bool
+}
+
+// ActionError is returned, and rendered as the observation under
+// ErrorPolicyCollectAll, when an action still fails after its last attempt.
+type ActionError struct {
+	Tool     string
+	Attempts int
+	Err      error
+}
+
+func (e *ActionError) Error() string {
+	if e.Attempts > 1 {
+		return fmt.Sprintf("%s failed after %d attempts: %s", e.Tool, e.Attempts, e.Err)
+	}
+	return fmt.Sprintf("%s failed: %s", e.Tool, e.Err)
+}
+
+func (e *ActionError) Unwrap() error {
+	return e.Err
+}
+
+// actionAttempts records how many attempts the actions of a run took, keyed
+// by ToolID, whether they succeeded or not.
+type actionAttempts struct {
+	mu   sync.Mutex
+	byID map[string]int
+}
+
+func (a *actionAttempts) record(toolID string, attempts int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.byID == nil {
+		a.byID = make(map[string]int)
+	}
+	a.byID[toolID] = attempts
+}
+
+func (a *actionAttempts) snapshot() map[string]int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.byID == nil {
+		return make(map[string]int)
+	}
+	return maps.Clone(a.byID)
+}
+
+// policyFor returns the policy of the tool, falling back to the executor wide
+// ActionPolicy.
+func (e *Executor) policyFor(tool string) ActionPolicy {
+	for name, policy := range e.ToolPolicies {
+		if strings.EqualFold(name, tool) {
+			return policy
+		}
+	}
+
+	return e.ActionPolicy
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+
+	return IsTransientError(err)
+}
+
+// backoff returns the wait after the given failed attempt, counted from 1.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	initial, maxBackoff, factor, jitter := p.InitialBackoff, p.MaxBackoff, p.Multiplier, p.Jitter
+	if initial <= 0 {
+		initial = _defaultInitialBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = _defaultMaxBackoff
+	}
+	if factor <= 0 {
+		factor = _defaultBackoffFactor
+	}
+	if jitter == 0 {
+		jitter = _defaultBackoffJitter
+	}
+
+	d := float64(initial)
+	for i := 1; i < attempt && d < float64(maxBackoff); i++ {
+		d *= factor
+	}
+	d = min(d, float64(maxBackoff))
+	if jitter > 0 {
+		d += d * jitter * (2*rand.Float64() - 1) //nolint:gosec
+	}
+
+	return time.Duration(d)
+}
+
+// IsTransientError reports whether err is likely to go away on retry: attempt
+// timeouts, network timeouts, reset or refused connections, and errors
+// carrying a 429 or 5xx status code through a StatusCode() int method, such
+// as the status errors of the bocha, google_serper and qweather clients.
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var statusErr interface{ StatusCode() int }
+	if errors.As(err, &statusErr) {
+		code := statusErr.StatusCode()
+		return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+	}
+
+	return false
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
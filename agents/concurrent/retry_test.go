@@ -0,0 +1,121 @@
+package concurrent
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/tools"
+)
+
+// statusError mimics the status errors of the tool clients.
+type statusError struct{ code int }
+
+func (e statusError) Error() string   { return "search in api, status code: " + strconv.Itoa(e.code) }
+func (e statusError) StatusCode() int { return e.code }
+
+func TestIsTransientError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{statusError{429}, true},
+		{fmt.Errorf("search: %w", statusError{503}), true},
+		{statusError{404}, false},
+		{context.DeadlineExceeded, true},
+		{errBoom, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsTransientError(tt.err); got != tt.want {
+			t.Errorf("IsTransientError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestExecutorRetry(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		failures     int32
+		err          error
+		wantObs      string
+		wantAttempts int
+	}{
+		{
+			name:         "transient errors are retried",
+			failures:     2,
+			err:          statusError{503},
+			wantObs:      "flaky: ok",
+			wantAttempts: 3,
+		},
+		{
+			name:         "first attempt succeeds",
+			wantObs:      "flaky: ok",
+			wantAttempts: 1,
+		},
+		{
+			name:         "permanent errors are not retried",
+			failures:     2,
+			err:          statusError{400},
+			wantObs:      "flaky failed: search in api, status code: 400",
+			wantAttempts: 1,
+		},
+		{
+			name:         "attempts run out",
+			failures:     5,
+			err:          statusError{429},
+			wantObs:      "flaky failed after 3 attempts: search in api, status code: 429",
+			wantAttempts: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var failed atomic.Int32
+			flaky := newFakeTool("flaky", func(_ context.Context, input string) (string, error) {
+				if failed.Add(1) <= tt.failures {
+					return "", tt.err
+				}
+				return "flaky: " + input, nil
+			})
+			agent := &scriptedAgent{
+				tools: []tools.Tool{flaky},
+				plans: [][]schema.AgentAction{actions("flaky", "ok")},
+			}
+			executor := NewExecutor(agent, Options{
+				MaxIterations:           3,
+				ErrorPolicy:             ErrorPolicyCollectAll,
+				ReturnIntermediateSteps: true,
+				ToolPolicies: map[string]ActionPolicy{"FLAKY": {Retry: RetryPolicy{
+					MaxAttempts:    3,
+					InitialBackoff: time.Millisecond,
+				}}},
+			})
+
+			out, err := executor.Call(context.Background(), map[string]any{"input": "q"})
+			if err != nil {
+				t.Fatalf("Call: %v", err)
+			}
+			steps, _ := out[_intermediateStepsOutputKey].([]schema.AgentStep)
+			if got := observations(steps); !slices.Equal(got, []string{tt.wantObs}) {
+				t.Errorf("observations = %q, want %q", got, tt.wantObs)
+			}
+			attempts, _ := out[_actionAttemptsOutputKey].(map[string]int)
+			want := map[string]int{actionID(0, 0): tt.wantAttempts}
+			if !maps.Equal(attempts, want) {
+				t.Errorf("%s = %v, want %v", _actionAttemptsOutputKey, attempts, want)
+			}
+		})
+	}
+}
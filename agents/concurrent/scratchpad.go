@@ -0,0 +1,81 @@
+package concurrent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tmc/langchaingo/schema"
+)
+
+// ScratchpadFormatter renders the intermediate steps of a run into the
+// agent_scratchpad prompt variable. Steps are given in plan order.
+type ScratchpadFormatter func(steps []schema.AgentStep) string
+
+// actionID returns the id of the i-th action of a plan made after the given
+// number of steps. Steps only ever grow during a run, so ids are unique and
+// stable for the whole run.
+func actionID(priorSteps, i int) string {
+	return strconv.Itoa(priorSteps + i + 1)
+}
+
+// actionLog is the text form of an action, stored in AgentAction.Log.
+func actionLog(action schema.AgentAction) string {
+	return fmt.Sprintf("Action[%s]: %s\nActionInput: %s", action.ToolID, action.Tool, action.ToolInput)
+}
+
+// TextScratchpad renders each step as
+//
+//	Action[id]: tool
+//	ActionInput: input
+//	Observation[id]: observation
+//
+// so the model can match every observation to the action that produced it.
+func TextScratchpad(steps []schema.AgentStep) string {
+	var sb strings.Builder
+	for _, step := range steps {
+		if step.Action.Tool == "" {
+			sb.WriteString("\nObservation: " + step.Observation + "\n")
+			continue
+		}
+		log := step.Action.Log
+		if log == "" {
+			log = actionLog(step.Action)
+		}
+		sb.WriteString("\n" + log)
+		sb.WriteString(fmt.Sprintf("\nObservation[%s]: %s\n", step.Action.ToolID, step.Observation))
+	}
+
+	return sb.String()
+}
+
+type scratchpadEntry struct {
+	ID          string `json:"ID,omitempty"`
+	Action      string `json:"Action,omitempty"`
+	ActionInput string `json:"ActionInput,omitempty"`
+	Observation string `json:"Observation"`
+}
+
+// JSONScratchpad renders the steps as a JSON array of
+// {"ID", "Action", "ActionInput", "Observation"} objects.
+func JSONScratchpad(steps []schema.AgentStep) string {
+	if len(steps) == 0 {
+		return ""
+	}
+	entries := make([]scratchpadEntry, 0, len(steps))
+	for _, step := range steps {
+		entries = append(entries, scratchpadEntry{
+			ID:          step.Action.ToolID,
+			Action:      step.Action.Tool,
+			ActionInput: step.Action.ToolInput,
+			Observation: step.Observation,
+		})
+	}
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return TextScratchpad(steps)
+	}
+
+	return "\n" + string(b) + "\n"
+}
@@ -0,0 +1,120 @@
+package concurrent
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/tools"
+)
+
+var _scratchpadSteps = []schema.AgentStep{
+	{
+		Action:      schema.AgentAction{Tool: "search", ToolInput: "paris", ToolID: "1", Log: "Action[1]: search\nActionInput: paris"},
+		Observation: "found paris",
+	},
+	{Action: schema.AgentAction{Tool: "weather", ToolInput: `{"city":"paris"}`, ToolID: "2"}, Observation: "sunny"},
+	{Observation: "the plan could not be parsed"},
+}
+
+func TestTextScratchpad(t *testing.T) {
+	t.Parallel()
+
+	want := "\nAction[1]: search\nActionInput: paris\nObservation[1]: found paris\n" +
+		"\nAction[2]: weather\nActionInput: {\"city\":\"paris\"}\nObservation[2]: sunny\n" +
+		"\nObservation: the plan could not be parsed\n"
+	if got := TextScratchpad(_scratchpadSteps); got != want {
+		t.Errorf("TextScratchpad = %q, want %q", got, want)
+	}
+	if got := TextScratchpad(nil); got != "" {
+		t.Errorf("TextScratchpad(nil) = %q, want it empty", got)
+	}
+}
+
+func TestJSONScratchpad(t *testing.T) {
+	t.Parallel()
+
+	var entries []scratchpadEntry
+	if err := json.Unmarshal([]byte(JSONScratchpad(_scratchpadSteps)), &entries); err != nil {
+		t.Fatalf("JSONScratchpad is not JSON: %v", err)
+	}
+	want := []scratchpadEntry{
+		{ID: "1", Action: "search", ActionInput: "paris", Observation: "found paris"},
+		{ID: "2", Action: "weather", ActionInput: `{"city":"paris"}`, Observation: "sunny"},
+		{Observation: "the plan could not be parsed"},
+	}
+	if !slices.Equal(entries, want) {
+		t.Errorf("entries = %+v, want %+v", entries, want)
+	}
+	if got := JSONScratchpad(nil); got != "" {
+		t.Errorf("JSONScratchpad(nil) = %q, want it empty", got)
+	}
+}
+
+func TestScratchpadPlanOrder(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		formatter ScratchpadFormatter
+		first     string
+		second    string
+	}{
+		{name: "text", formatter: TextScratchpad, first: "Observation[1]: slow a", second: "Observation[2]: fast b"},
+		{name: "json", formatter: JSONScratchpad, first: `"Observation": "slow a"`, second: `"Observation": "fast b"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			// The first action only ends once the second one did.
+			fastDone := make(chan struct{})
+			slow := newFakeTool("slow", func(ctx context.Context, input string) (string, error) {
+				select {
+				case <-fastDone:
+				case <-ctx.Done():
+					return "", ctx.Err()
+				}
+				return "slow " + input, nil
+			})
+			fast := newFakeTool("fast", func(_ context.Context, input string) (string, error) {
+				defer close(fastDone)
+				return "fast " + input, nil
+			})
+			llm := &fakeLLM{replies: []*llms.ContentResponse{
+				textReply(`{"Actions": [{"Action": "slow", "ActionInput": "a"}, {"Action": "fast", "ActionInput": "b"}]}`),
+				textReply(`{"FinalAnswer": "done"}`),
+			}}
+			agent, err := NewConcurrentAgentWithOptions(llm, []tools.Tool{slow, fast}, WithScratchpadFormatter(tt.formatter))
+			if err != nil {
+				t.Fatalf("NewConcurrentAgentWithOptions: %v", err)
+			}
+			executor := NewExecutor(agent, Options{MaxIterations: 3})
+			if _, err := executor.Call(context.Background(), map[string]any{"input": "q"}); err != nil {
+				t.Fatalf("Call: %v", err)
+			}
+
+			sent := llm.sent()
+			if len(sent) != 2 {
+				t.Fatalf("%d model calls, want 2", len(sent))
+			}
+			var prompt strings.Builder
+			for _, message := range sent[1] {
+				for _, part := range message.Parts {
+					if text, ok := part.(llms.TextContent); ok {
+						prompt.WriteString(text.Text)
+					}
+				}
+			}
+			first, second := strings.Index(prompt.String(), tt.first), strings.Index(prompt.String(), tt.second)
+			if first < 0 || second < first {
+				t.Errorf("scratchpad has %q at %d and %q at %d, want both in plan order:\n%s",
+					tt.first, first, tt.second, second, prompt.String())
+			}
+		})
+	}
+}
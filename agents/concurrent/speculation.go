@@ -0,0 +1,173 @@
+package concurrent
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/tmc/langchaingo/schema"
+)
+
+type speculatorKey struct{}
+
+// withSpeculator asks the agent planning under ctx to report the actions of
+// the plan to speculate as soon as they are streamed.
+func withSpeculator(ctx context.Context, speculate func(schema.AgentAction)) context.Context {
+	return context.WithValue(ctx, speculatorKey{}, speculate)
+}
+
+func speculatorFromContext(ctx context.Context) func(schema.AgentAction) {
+	speculate, _ := ctx.Value(speculatorKey{}).(func(schema.AgentAction))
+	return speculate
+}
+
+// speculation holds the actions of an iteration started while its plan was
+// still streaming. A nil speculation is a no-op.
+type speculation struct {
+	executor *Executor
+	run      *runState
+	ctx      context.Context //nolint:containedctx
+
+	mu      sync.Mutex
+	actions map[string]*speculativeAction
+	// closed is set once the plan is known; later actions are not started.
+	closed bool
+}
+
+type speculativeAction struct {
+	action schema.AgentAction
+	cancel context.CancelFunc
+	done   chan struct{}
+	step   schema.AgentStep
+	err    error
+}
+
+func newSpeculation(ctx context.Context, e *Executor, run *runState) *speculation {
+	return &speculation{
+		executor: e,
+		run:      run,
+		ctx:      ctx,
+		actions:  make(map[string]*speculativeAction),
+	}
+}
+
+// start runs a streamed action unless it already started or its result is
+// already known. With ReuseActionResults, a repeat of a started action is not
+// started either: runActions answers it from the first occurrence.
+func (s *speculation) start(action schema.AgentAction) {
+	action = s.executor.resolveAction(s.ctx, s.run, action)
+	if s.executor.ReuseActionResults {
+		if _, ok := s.run.cache.load(action); ok {
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.actions[action.ToolID]; ok || s.closed {
+		return
+	}
+	if s.executor.ReuseActionResults {
+		key := actionKey(action)
+		for _, sa := range s.actions {
+			if actionKey(sa.action) == key {
+				return
+			}
+		}
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	sa := &speculativeAction{action: action, cancel: cancel, done: make(chan struct{})}
+	s.actions[action.ToolID] = sa
+
+	s.run.log(ctx, slog.LevelDebug, "speculative action started",
+		slog.String("tool", action.Tool),
+		slog.String("tool_id", action.ToolID))
+	go func() {
+		defer close(sa.done)
+		defer cancel()
+		sa.step, sa.err = s.executor.executeAction(ctx, s.run, action)
+	}()
+}
+
+// keep discards the speculative actions that the final plan does not contain
+// as independent actions with the same tool and input.
+func (s *speculation) keep(ctx context.Context, actions []schema.AgentAction, deps ActionDependencies) {
+	if s == nil {
+		return
+	}
+	planned := make(map[string]schema.AgentAction, len(actions))
+	for _, action := range actions {
+		if len(deps[action.ToolID]) == 0 {
+			planned[action.ToolID] = action
+		}
+	}
+
+	s.mu.Lock()
+	s.closed = true
+	var discarded []*speculativeAction
+	for id, sa := range s.actions {
+		if action, ok := planned[id]; !ok || !sameAction(sa.action, action) {
+			discarded = append(discarded, sa)
+			delete(s.actions, id)
+		}
+	}
+	s.mu.Unlock()
+	s.cancel(ctx, discarded)
+}
+
+// take hands over the speculative run of action, if any.
+func (s *speculation) take(action schema.AgentAction) (*speculativeAction, bool) {
+	if s == nil {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sa, ok := s.actions[action.ToolID]
+	if !ok || !sameAction(sa.action, action) {
+		return nil, false
+	}
+	delete(s.actions, action.ToolID)
+
+	return sa, true
+}
+
+// discard cancels the speculative actions that were not taken.
+func (s *speculation) discard(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.closed = true
+	discarded := make([]*speculativeAction, 0, len(s.actions))
+	for _, sa := range s.actions {
+		discarded = append(discarded, sa)
+	}
+	s.actions = make(map[string]*speculativeAction)
+	s.mu.Unlock()
+	s.cancel(ctx, discarded)
+}
+
+// cancel stops the actions and waits for them to exit.
+func (s *speculation) cancel(ctx context.Context, actions []*speculativeAction) {
+	for _, sa := range actions {
+		sa.cancel()
+		<-sa.done
+		s.run.log(ctx, slog.LevelDebug, "speculative action discarded",
+			slog.String("tool", sa.action.Tool),
+			slog.String("tool_id", sa.action.ToolID))
+	}
+}
+
+// wait returns the result of a taken action. The action is cancelled if ctx
+// is done first.
+func (sa *speculativeAction) wait(ctx context.Context) (schema.AgentStep, error) {
+	stop := context.AfterFunc(ctx, sa.cancel)
+	defer stop()
+	<-sa.done
+
+	return sa.step, sa.err
+}
+
+func sameAction(a, b schema.AgentAction) bool {
+	return a.Tool == b.Tool && a.ToolInput == b.ToolInput
+}
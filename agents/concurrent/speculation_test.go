@@ -0,0 +1,191 @@
+package concurrent
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tmc/langchaingo/agents"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/tools"
+)
+
+// streamingLLM streams each reply in its chunks, calling between before the
+// second chunk of the first reply.
+type streamingLLM struct {
+	replies [][]string
+	between func(ctx context.Context) error
+
+	calls atomic.Int32
+}
+
+func (m *streamingLLM) GenerateContent(
+	ctx context.Context,
+	_ []llms.MessageContent,
+	options ...llms.CallOption,
+) (*llms.ContentResponse, error) {
+	var opts llms.CallOptions
+	for _, opt := range options {
+		opt(&opts)
+	}
+	call := int(m.calls.Add(1)) - 1
+	chunks := m.replies[min(call, len(m.replies)-1)]
+
+	var content string
+	for i, chunk := range chunks {
+		if i == 1 && call == 0 && m.between != nil {
+			if err := m.between(ctx); err != nil {
+				return nil, err
+			}
+		}
+		if opts.StreamingFunc != nil {
+			if err := opts.StreamingFunc(ctx, []byte(chunk)); err != nil {
+				return nil, err
+			}
+		}
+		content += chunk
+	}
+
+	return textReply(content), nil
+}
+
+func (m *streamingLLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+func TestExecutorSpeculativeActions(t *testing.T) {
+	t.Parallel()
+
+	const action = `{"Thought": "look", "Actions": [{"Action": "Slow", "ActionInput": "a"}`
+	tests := []struct {
+		name        string
+		rest        string
+		wantCalls   int32
+		wantAborted int32
+		wantObs     string
+	}{
+		{
+			name:      "kept when the plan confirms it",
+			rest:      `]}`,
+			wantCalls: 1,
+			wantObs:   "slow a",
+		},
+		{
+			name:        "cancelled when the plan cannot be parsed",
+			rest:        `, {"Action": `,
+			wantCalls:   1,
+			wantAborted: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			started := make(chan struct{}, 1)
+			var aborted atomic.Int32
+			slow := newFakeTool("slow", func(ctx context.Context, input string) (string, error) {
+				started <- struct{}{}
+				select {
+				case <-ctx.Done():
+					aborted.Add(1)
+					return "", ctx.Err()
+				case <-time.After(50 * time.Millisecond):
+					return "slow " + input, nil
+				}
+			})
+			llm := &streamingLLM{
+				replies: [][]string{{action, tt.rest}, {`{"FinalAnswer": "done"}`}},
+				// The plan is only finished once its first action runs.
+				between: func(ctx context.Context) error {
+					select {
+					case <-started:
+						return nil
+					case <-time.After(2 * time.Second):
+						return errors.New("the streamed action did not start")
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				},
+			}
+			agent := NewConcurrentAgent(llm, []tools.Tool{slow})
+			executor := NewExecutor(agent, Options{
+				MaxIterations:           3,
+				SpeculativeActions:      true,
+				ReturnIntermediateSteps: true,
+				ErrorHandler:            agents.NewParserErrorHandler(nil),
+			})
+
+			out, err := executor.Call(context.Background(), map[string]any{"input": "q"})
+			if err != nil {
+				t.Fatalf("Call: %v", err)
+			}
+			if got := slow.calls.Load(); got != tt.wantCalls {
+				t.Errorf("tool calls = %d, want %d", got, tt.wantCalls)
+			}
+			if got := aborted.Load(); got != tt.wantAborted {
+				t.Errorf("aborted = %d, want %d", got, tt.wantAborted)
+			}
+			steps, _ := out[_intermediateStepsOutputKey].([]schema.AgentStep)
+			if tt.wantObs != "" && (len(steps) != 1 || steps[0].Observation != tt.wantObs || steps[0].Action.Tool != "slow") {
+				t.Errorf("steps = %+v, want one slow step with %q", steps, tt.wantObs)
+			}
+		})
+	}
+}
+
+func TestExecutorNoSpeculationWithApprover(t *testing.T) {
+	t.Parallel()
+
+	search := newFakeTool("search", nil)
+	llm := &fakeLLM{replies: []*llms.ContentResponse{
+		textReply(`{"Actions": [{"Action": "search", "ActionInput": "a"}]}`),
+		textReply(`{"FinalAnswer": "done"}`),
+	}}
+	executor := NewExecutor(NewConcurrentAgent(llm, []tools.Tool{search}), Options{
+		MaxIterations:      3,
+		SpeculativeActions: true,
+		Approver: ApproverFunc(func(_ context.Context, _ string, planned []schema.AgentAction) ([]Decision, error) {
+			if got := search.calls.Load(); got != 0 {
+				t.Errorf("tool called %d times before the approval", got)
+			}
+			return make([]Decision, len(planned)), nil
+		}),
+	})
+
+	if _, err := executor.Call(context.Background(), map[string]any{"input": "q"}); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if got := search.calls.Load(); got != 1 {
+		t.Errorf("tool calls = %d, want 1", got)
+	}
+}
+
+func TestExecutorSpeculationReusesRepeatedActions(t *testing.T) {
+	t.Parallel()
+
+	search := newFakeTool("search", nil)
+	llm := &fakeLLM{replies: []*llms.ContentResponse{
+		textReply(`{"Actions": [{"Action": "search", "ActionInput": "x"}, {"Action": "search", "ActionInput": "x"}]}`),
+		textReply(`{"FinalAnswer": "done"}`),
+	}}
+	executor := NewExecutor(NewConcurrentAgent(llm, []tools.Tool{search}), Options{
+		MaxIterations:      3,
+		SpeculativeActions: true,
+		ReuseActionResults: true,
+		ReturnUsage:        true,
+	})
+
+	out, err := executor.Call(context.Background(), map[string]any{"input": "q"})
+	if err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if got := search.calls.Load(); got != 1 {
+		t.Errorf("tool calls = %d, want 1", got)
+	}
+	if usage, _ := out[_usageOutputKey].(Usage); usage.ToolCalls["search"] != 1 {
+		t.Errorf("usage tool calls = %v, want search: 1", usage.ToolCalls)
+	}
+}
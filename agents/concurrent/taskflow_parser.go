@@ -0,0 +1,283 @@
+package concurrent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tmc/langchaingo/agents"
+)
+
+var _fencedBlock = regexp.MustCompile("(?s)```[a-zA-Z]*\\s*\\n?(.*?)```")
+
+// parseTaskFlow extracts, repairs and validates the TaskFlow in a model output.
+// Every error wraps agents.ErrUnableToParseOutput and says what the model has
+// to fix.
+func parseTaskFlow(output string) (TaskFlow, error) {
+	var task TaskFlow
+
+	raw, err := extractJSONObject(output)
+	if err != nil {
+		return task, parseError(err)
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+		repaired := repairJSON(raw)
+		if rerr := json.Unmarshal([]byte(repaired), &fields); rerr != nil {
+			return task, parseError(describeJSONError(repaired, rerr))
+		}
+		raw = repaired
+	}
+
+	if err := validateTaskFlow(fields); err != nil {
+		return task, parseError(err)
+	}
+	if err := json.Unmarshal([]byte(raw), &task); err != nil {
+		return task, parseError(err)
+	}
+
+	return task, nil
+}
+
+func parseError(err error) error {
+	return fmt.Errorf("%w: %s. Reply with a single JSON object following the output example", agents.ErrUnableToParseOutput, err) //nolint:lll
+}
+
+// extractJSONObject returns the first JSON object in output that decodes,
+// once repaired, into an object with TaskFlow keys. Fenced code blocks are
+// looked at first, then the whole output, trying every opening brace so that
+// braces in the surrounding prose are skipped. When no object decodes, the
+// first one that is never closed is returned up to the end of the text, or
+// else the first closed one, so that repairJSON and the decoder can report on
+// it.
+func extractJSONObject(output string) (string, error) {
+	candidates := make([]string, 0, 2)
+	for _, m := range _fencedBlock.FindAllStringSubmatch(output, -1) {
+		candidates = append(candidates, m[1])
+	}
+	candidates = append(candidates, output)
+
+	var unclosed, closed string
+	for _, text := range candidates {
+		for start := 0; start < len(text); start++ {
+			if text[start] != '{' {
+				continue
+			}
+			end := matchingBrace(text, start)
+			if end < 0 {
+				if unclosed == "" {
+					unclosed = strings.TrimSpace(text[start:])
+				}
+				continue
+			}
+			if isTaskFlowObject(text[start : end+1]) {
+				return text[start : end+1], nil
+			}
+			if closed == "" {
+				closed = text[start : end+1]
+			}
+		}
+	}
+
+	switch {
+	case unclosed != "":
+		return unclosed, nil
+	case closed != "":
+		return closed, nil
+	default:
+		return "", errors.New("no JSON object found in the output")
+	}
+}
+
+// isTaskFlowObject reports whether raw, repaired if needed, decodes into an
+// object with at least one TaskFlow key.
+func isTaskFlowObject(raw string) bool {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+		if err := json.Unmarshal([]byte(repairJSON(raw)), &fields); err != nil {
+			return false
+		}
+	}
+	for _, key := range []string{"Question", "Thought", "FinalAnswer", "Actions"} {
+		if _, ok := fields[key]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchingBrace returns the index of the brace closing the one at start,
+// skipping braces inside single or double quoted strings, or -1.
+func matchingBrace(text string, start int) int {
+	depth := 0
+	var quote byte
+	for i := start; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+// repairJSON fixes the defects models commonly produce: single quoted
+// strings, raw control characters inside strings and trailing commas.
+func repairJSON(s string) string {
+	var out bytes.Buffer
+	out.Grow(len(s))
+
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			switch {
+			case c == '\\' && i+1 < len(s):
+				if quote == '\'' && s[i+1] == '\'' {
+					out.WriteByte('\'')
+				} else {
+					out.WriteByte(c)
+					out.WriteByte(s[i+1])
+				}
+				i++
+			case c == quote:
+				out.WriteByte('"')
+				quote = 0
+			case c == '"':
+				out.WriteString(`\"`)
+			case c == '\n':
+				out.WriteString(`\n`)
+			case c == '\r':
+				out.WriteString(`\r`)
+			case c == '\t':
+				out.WriteString(`\t`)
+			default:
+				out.WriteByte(c)
+			}
+			continue
+		}
+
+		switch c {
+		case '"', '\'':
+			quote = c
+			out.WriteByte('"')
+		case ',':
+			if next := nextNonSpace(s, i+1); next == '}' || next == ']' {
+				continue
+			}
+			out.WriteByte(c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+
+	return out.String()
+}
+
+func nextNonSpace(s string, from int) byte {
+	for i := from; i < len(s); i++ {
+		switch s[i] {
+		case ' ', '\n', '\r', '\t':
+			continue
+		default:
+			return s[i]
+		}
+	}
+
+	return 0
+}
+
+// describeJSONError points at the place a decode error occurred.
+func describeJSONError(s string, err error) error {
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	offset := int(syntaxErr.Offset)
+	from := max(offset-20, 0)
+	to := min(offset+20, len(s))
+
+	return fmt.Errorf("invalid JSON at offset %d near %q: %w", offset, s[from:to], err)
+}
+
+// validateTaskFlow checks the decoded fields against the TaskFlow schema.
+func validateTaskFlow(fields map[string]json.RawMessage) error {
+	for _, key := range []string{"Question", "Thought", "FinalAnswer"} {
+		if v, ok := fields[key]; ok && !isJSONString(v) && !isJSONNull(v) {
+			return fmt.Errorf("%q must be a string", key)
+		}
+	}
+
+	var finalAnswer string
+	if v, ok := fields["FinalAnswer"]; ok {
+		_ = json.Unmarshal(v, &finalAnswer)
+	}
+
+	var items []map[string]json.RawMessage
+	if v, ok := fields["Actions"]; ok && !isJSONNull(v) {
+		if err := json.Unmarshal(v, &items); err != nil {
+			return errors.New(`"Actions" must be a list of {"Action", "ActionInput"} objects`)
+		}
+	}
+
+	if finalAnswer == "" && len(items) == 0 {
+		return errors.New(`either "FinalAnswer" must be a non empty string or "Actions" must contain at least one action`)
+	}
+	if finalAnswer != "" {
+		return nil
+	}
+
+	for i, item := range items {
+		action, ok := item["Action"]
+		if !ok || !isJSONString(action) || string(action) == `""` {
+			return fmt.Errorf(`"Actions"[%d]."Action" must be the name of a tool`, i)
+		}
+		if input, ok := item["ActionInput"]; ok && !isJSONString(input) && !isJSONObject(input) {
+			return fmt.Errorf(`"Actions"[%d]."ActionInput" must be a string or a JSON object`, i)
+		}
+		if id, ok := item["ID"]; ok && !isJSONString(id) && !isJSONNull(id) {
+			return fmt.Errorf(`"Actions"[%d]."ID" must be a string`, i)
+		}
+		if deps, ok := item["DependsOn"]; ok && !isJSONNull(deps) {
+			var ids []string
+			if err := json.Unmarshal(deps, &ids); err != nil {
+				return fmt.Errorf(`"Actions"[%d]."DependsOn" must be a list of action IDs`, i)
+			}
+		}
+	}
+
+	return nil
+}
+
+func isJSONString(v json.RawMessage) bool {
+	v = bytes.TrimSpace(v)
+	return len(v) > 0 && v[0] == '"'
+}
+
+func isJSONObject(v json.RawMessage) bool {
+	v = bytes.TrimSpace(v)
+	return len(v) > 0 && v[0] == '{'
+}
+
+func isJSONNull(v json.RawMessage) bool {
+	return string(bytes.TrimSpace(v)) == "null"
+}
@@ -0,0 +1,68 @@
+package concurrent
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tmc/langchaingo/agents"
+)
+
+func TestParseTaskFlow(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		output     string
+		wantAnswer string
+		wantAction string
+		wantErr    bool
+	}{
+		{
+			name:       "braces in the prose before the object",
+			output:     `I think {maybe}. {"FinalAnswer":"ok"}`,
+			wantAnswer: "ok",
+		},
+		{
+			name:       "object without TaskFlow keys in the prose",
+			output:     "Input: {\"city\": \"Paris\"}\n{'Thought': 'look it up', 'Actions': [{'Action': 'search', 'ActionInput': 'x',}]}",
+			wantAction: "search",
+		},
+		{
+			name:       "second fenced block",
+			output:     "```json\n{\"note\": 1}\n```\n```json\n{\"FinalAnswer\": \"fenced\"}\n```",
+			wantAnswer: "fenced",
+		},
+		{
+			name:    "truncated object",
+			output:  `{maybe} {"Thought": "x", "Actions": [{"Action": "search"`,
+			wantErr: true,
+		},
+		{
+			name:    "no object",
+			output:  "just text",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			task, err := parseTaskFlow(tt.output)
+			if tt.wantErr {
+				if !errors.Is(err, agents.ErrUnableToParseOutput) {
+					t.Fatalf("parseTaskFlow error = %v, want ErrUnableToParseOutput", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTaskFlow: %v", err)
+			}
+			if task.FinalAnswer != tt.wantAnswer {
+				t.Errorf("FinalAnswer = %q, want %q", task.FinalAnswer, tt.wantAnswer)
+			}
+			if tt.wantAction != "" && (len(task.Actions) != 1 || task.Actions[0].Action != tt.wantAction) {
+				t.Errorf("Actions = %+v, want one %s action", task.Actions, tt.wantAction)
+			}
+		})
+	}
+}
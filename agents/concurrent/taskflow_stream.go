@@ -0,0 +1,266 @@
+package concurrent
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/tmc/langchaingo/schema"
+)
+
+const _finalAnswerKey = "FinalAnswer"
+
+// taskFlowStream follows the TaskFlow in a model output while it is streamed.
+// It tracks the structure of the first JSON object of the output byte by
+// byte and reports, as soon as they arrive:
+//   - onAction: each entry of the "Actions" list, once its closing brace
+//     arrived, with its index in the list;
+//   - onAnswer: the decoded text of the "FinalAnswer" string;
+//   - onThinking: every other part of the output, unchanged.
+//
+// Any of them can be nil.
+type taskFlowStream struct {
+	onAction   func(index int, raw string)
+	onAnswer   func(text string)
+	onThinking func(chunk []byte)
+
+	buf   []byte
+	depth int
+	quote byte
+	// escaped is set after a backslash inside a string.
+	escaped  bool
+	strStart int
+	// lastString is the last string closed at the top level of the object,
+	// key is the top-level key whose value is being read.
+	lastString string
+	key        string
+	// actionsDepth is the depth of the "Actions" list while inside it.
+	actionsDepth int
+	itemStart    int
+	index        int
+	// answer decodes the "FinalAnswer" string while inside it.
+	answer *stringDecoder
+	done   bool
+}
+
+// write consumes the next chunk of the output.
+func (s *taskFlowStream) write(chunk []byte) {
+	thinking := make([]byte, 0, len(chunk))
+	for _, c := range chunk {
+		if s.answer != nil {
+			if s.answer.feed(c) {
+				s.answer.flushHigh()
+				s.emitAnswer(true)
+				s.answer = nil
+				s.quote = 0
+				thinking = append(thinking, c)
+			}
+			continue
+		}
+		thinking = append(thinking, c)
+		if s.done {
+			continue
+		}
+		s.buf = append(s.buf, c)
+		s.consume(c, len(s.buf)-1)
+	}
+
+	if len(thinking) > 0 && s.onThinking != nil {
+		s.onThinking(thinking)
+	}
+	s.emitAnswer(false)
+}
+
+func (s *taskFlowStream) consume(c byte, pos int) {
+	if s.depth == 0 && c != '{' {
+		return
+	}
+	if s.quote != 0 {
+		switch {
+		case s.escaped:
+			s.escaped = false
+		case c == '\\':
+			s.escaped = true
+		case c == s.quote:
+			s.quote = 0
+			if s.depth == 1 {
+				s.lastString = string(s.buf[s.strStart+1 : pos])
+			}
+		}
+		return
+	}
+
+	switch c {
+	case '"', '\'':
+		s.quote = c
+		s.strStart = pos
+		if s.depth == 1 && s.key == _finalAnswerKey {
+			s.answer = &stringDecoder{quote: c}
+		}
+	case ':':
+		if s.depth == 1 {
+			s.key = s.lastString
+		}
+	case ',':
+		if s.depth == 1 {
+			s.key = ""
+		}
+	case '{', '[':
+		s.depth++
+		if c == '[' && s.depth == 2 && s.key == "Actions" {
+			s.actionsDepth = s.depth
+		}
+		if c == '{' && s.actionsDepth > 0 && s.depth == s.actionsDepth+1 {
+			s.itemStart = pos
+		}
+	case '}', ']':
+		if c == '}' && s.actionsDepth > 0 && s.depth == s.actionsDepth+1 {
+			if s.onAction != nil {
+				s.onAction(s.index, string(s.buf[s.itemStart:pos+1]))
+			}
+			s.index++
+		}
+		if c == ']' && s.depth == s.actionsDepth {
+			s.actionsDepth = 0
+		}
+		s.depth--
+		s.done = s.depth == 0
+	}
+}
+
+// emitAnswer hands the decoded answer text to onAnswer. Until the string is
+// closed, a rune whose bytes have not all arrived is kept back.
+func (s *taskFlowStream) emitAnswer(closed bool) {
+	if s.answer == nil || len(s.answer.out) == 0 {
+		return
+	}
+	n := len(s.answer.out)
+	if !closed {
+		n = completeUTF8(s.answer.out)
+	}
+	if n == 0 {
+		return
+	}
+	if s.onAnswer != nil {
+		s.onAnswer(string(s.answer.out[:n]))
+	}
+	s.answer.out = s.answer.out[:copy(s.answer.out, s.answer.out[n:])]
+}
+
+// completeUTF8 returns the length of b without its trailing incomplete rune.
+func completeUTF8(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if utf8.FullRune(b[i:]) {
+				return len(b)
+			}
+			return i
+		}
+	}
+
+	return len(b)
+}
+
+// stringDecoder decodes the content of a JSON string, or of a single quoted
+// string as repairJSON accepts them, one byte at a time.
+type stringDecoder struct {
+	quote   byte
+	escaped bool
+	// hex holds the digits of a \u escape being read, nil otherwise.
+	hex []byte
+	// high is a high surrogate waiting for its low half.
+	high rune
+	out  []byte
+}
+
+// feed decodes c and reports whether it closed the string.
+func (d *stringDecoder) feed(c byte) bool {
+	switch {
+	case d.hex != nil:
+		d.hex = append(d.hex, c)
+		if len(d.hex) == 4 { //nolint:mnd
+			r, err := strconv.ParseUint(string(d.hex), 16, 32)
+			d.hex = nil
+			if err != nil {
+				r = utf8.RuneError
+			}
+			d.appendRune(rune(r))
+		}
+	case d.escaped:
+		d.escaped = false
+		switch c {
+		case 'u':
+			d.hex = make([]byte, 0, 4) //nolint:mnd
+		case 'n':
+			d.appendRune('\n')
+		case 't':
+			d.appendRune('\t')
+		case 'r':
+			d.appendRune('\r')
+		case 'b':
+			d.appendRune('\b')
+		case 'f':
+			d.appendRune('\f')
+		default:
+			d.appendRune(rune(c))
+		}
+	case c == '\\':
+		d.escaped = true
+	case c == d.quote:
+		return true
+	default:
+		d.flushHigh()
+		d.out = append(d.out, c)
+	}
+
+	return false
+}
+
+func (d *stringDecoder) appendRune(r rune) {
+	if r >= 0xD800 && r < 0xDC00 {
+		d.flushHigh()
+		d.high = r
+		return
+	}
+	if d.high != 0 && utf16.IsSurrogate(r) {
+		r = utf16.DecodeRune(d.high, r)
+		d.high = 0
+	}
+	d.flushHigh()
+	d.out = utf8.AppendRune(d.out, r)
+}
+
+// flushHigh writes a high surrogate that was not followed by its low half.
+func (d *stringDecoder) flushHigh() {
+	if d.high != 0 {
+		d.out = utf8.AppendRune(d.out, utf8.RuneError)
+		d.high = 0
+	}
+}
+
+// streamedAction decodes an entry of "Actions" found by a taskFlowStream into
+// the action parseOutput will make of it. Entries that cannot be decoded, or
+// that depend on other actions, are not returned: the final parse decides
+// about them.
+func streamedAction(raw string, priorSteps, index int) (schema.AgentAction, bool) {
+	var item ActionItem
+	if err := json.Unmarshal([]byte(raw), &item); err != nil {
+		if err := json.Unmarshal([]byte(repairJSON(raw)), &item); err != nil {
+			return schema.AgentAction{}, false
+		}
+	}
+	if strings.TrimSpace(item.Action) == "" || len(item.DependsOn) > 0 || _placeholder.MatchString(item.ActionInput) {
+		return schema.AgentAction{}, false
+	}
+
+	action := schema.AgentAction{
+		Tool:      item.Action,
+		ToolInput: item.ActionInput,
+		ToolID:    actionID(priorSteps, index),
+	}
+	action.Log = actionLog(action)
+
+	return action, true
+}
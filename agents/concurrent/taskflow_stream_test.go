@@ -0,0 +1,215 @@
+package concurrent
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/tmc/langchaingo/chains"
+	"github.com/tmc/langchaingo/tools"
+)
+
+// chunked cuts s into chunks of n bytes, the last one possibly shorter.
+func chunked(s string, n int) []string {
+	var chunks []string
+	for len(s) > n {
+		chunks = append(chunks, s[:n])
+		s = s[n:]
+	}
+
+	return append(chunks, s)
+}
+
+type streamed struct {
+	actions  []string
+	answer   []string
+	thinking string
+}
+
+func streamTaskFlow(output string, chunkSize int) streamed {
+	var got streamed
+	s := &taskFlowStream{
+		onAction: func(index int, raw string) {
+			if index != len(got.actions) {
+				raw = "out of order: " + raw
+			}
+			got.actions = append(got.actions, raw)
+		},
+		onAnswer:   func(text string) { got.answer = append(got.answer, text) },
+		onThinking: func(chunk []byte) { got.thinking += string(chunk) },
+	}
+	for _, chunk := range chunked(output, chunkSize) {
+		s.write([]byte(chunk))
+	}
+
+	return got
+}
+
+func TestTaskFlowStream(t *testing.T) {
+	t.Parallel()
+
+	const (
+		search  = `{"Action": "search", "ActionInput": "a {b} [c] \"FinalAnswer\""}`
+		weather = `{'Action': 'weather', 'ActionInput': {"city": "p"}}`
+	)
+	tests := []struct {
+		name    string
+		output  string
+		want    string
+		actions []string
+		// thinking is the output without the content of the answer string.
+		thinking string
+	}{
+		{
+			name:     "escapes",
+			output:   `{"Thought": "t", "FinalAnswer": "say \"hi\"\n\\ \/ \t"}`,
+			want:     "say \"hi\"\n\\ / \t",
+			thinking: `{"Thought": "t", "FinalAnswer": ""}`,
+		},
+		{
+			name:     "unicode escapes and surrogate pairs",
+			output:   `{"FinalAnswer": "caf\u00e9 \ud83d\ude00 \u5317"}`,
+			want:     "café 😀 北",
+			thinking: `{"FinalAnswer": ""}`,
+		},
+		{
+			name:     "unpaired high surrogate",
+			output:   `{"FinalAnswer": "\ud83d x"}`,
+			want:     "\uFFFD x",
+			thinking: `{"FinalAnswer": ""}`,
+		},
+		{
+			name:     "multibyte runes",
+			output:   `{"FinalAnswer": "北京 😀 été"}`,
+			want:     "北京 😀 été",
+			thinking: `{"FinalAnswer": ""}`,
+		},
+		{
+			name:     "single quotes",
+			output:   `{'Thought': 'x', 'FinalAnswer': 'it\'s "ok"'}`,
+			want:     `it's "ok"`,
+			thinking: `{'Thought': 'x', 'FinalAnswer': ''}`,
+		},
+		{
+			name:     "fenced output",
+			output:   "Here it is:\n```json\n{\"FinalAnswer\": \"ok\"}\n```",
+			want:     "ok",
+			thinking: "Here it is:\n```json\n{\"FinalAnswer\": \"\"}\n```",
+		},
+		{
+			name:     "empty answer",
+			output:   `{"Thought": "FinalAnswer", "FinalAnswer": ""}`,
+			thinking: `{"Thought": "FinalAnswer", "FinalAnswer": ""}`,
+		},
+		{
+			name:     "plan",
+			output:   `{"Thought": "look", "Actions": [` + search + `, ` + weather + `], "FinalAnswer": ""}`,
+			actions:  []string{search, weather},
+			thinking: `{"Thought": "look", "Actions": [` + search + `, ` + weather + `], "FinalAnswer": ""}`,
+		},
+		{
+			name:     "text after the object",
+			output:   `{"FinalAnswer": "a"} {"FinalAnswer": "b"}`,
+			want:     "a",
+			thinking: `{"FinalAnswer": ""} {"FinalAnswer": "b"}`,
+		},
+	}
+	for _, tt := range tests {
+		for _, size := range []int{1, 2, 3, len(tt.output)} {
+			got := streamTaskFlow(tt.output, size)
+			if answer := strings.Join(got.answer, ""); answer != tt.want {
+				t.Errorf("%s in chunks of %d: answer = %q, want %q", tt.name, size, answer, tt.want)
+			}
+			for _, chunk := range got.answer {
+				if chunk == "" || !utf8.ValidString(chunk) {
+					t.Errorf("%s in chunks of %d: answer chunk %q is empty or splits a rune", tt.name, size, chunk)
+				}
+			}
+			if !slices.Equal(got.actions, tt.actions) {
+				t.Errorf("%s in chunks of %d: actions = %q, want %q", tt.name, size, got.actions, tt.actions)
+			}
+			if got.thinking != tt.thinking {
+				t.Errorf("%s in chunks of %d: thinking = %q, want %q", tt.name, size, got.thinking, tt.thinking)
+			}
+		}
+	}
+}
+
+func TestStreamedAction(t *testing.T) {
+	t.Parallel()
+
+	action, ok := streamedAction(`{'Action': 'search', 'ActionInput': 'paris'}`, 2, 1)
+	if !ok || action.Tool != "search" || action.ToolInput != "paris" || action.ToolID != actionID(2, 1) {
+		t.Errorf("streamedAction = %+v, %v; want search paris with ID %s", action, ok, actionID(2, 1))
+	}
+	if action.Log != actionLog(action) {
+		t.Errorf("Log = %q, want %q", action.Log, actionLog(action))
+	}
+	for _, raw := range []string{
+		`{"Action": "search", "ActionInput": "x", "DependsOn": ["a"]}`,
+		`{"Action": "search", "ActionInput": "${a}"}`,
+		`{"Action": " ", "ActionInput": "x"}`,
+		`{"Action": `,
+	} {
+		if action, ok := streamedAction(raw, 0, 0); ok {
+			t.Errorf("streamedAction(%s) = %+v, want it left to the final parse", raw, action)
+		}
+	}
+}
+
+// answerHandler is a recordingHandler that wants the answer split from the
+// rest of the output.
+type answerHandler struct {
+	recordingHandler
+
+	answer   []string
+	thinking string
+}
+
+func (h *answerHandler) HandleAnswerChunk(_ context.Context, chunk string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.answer = append(h.answer, chunk)
+}
+
+func (h *answerHandler) HandleThinkingChunk(_ context.Context, chunk []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.thinking += string(chunk)
+}
+
+func TestConcurrentAgentAnswerStreamHandler(t *testing.T) {
+	t.Parallel()
+
+	const (
+		plan   = `{"Thought": "look", "Actions": [{"Action": "search", "ActionInput": "paris"}]}`
+		answer = `{"Thought": "done", "FinalAnswer": "Paris: été ☀"}`
+	)
+	llm := &streamingLLM{replies: [][]string{chunked(plan, 1), chunked(answer, 1)}}
+	agent := NewConcurrentAgent(llm, []tools.Tool{newFakeTool("search", nil)})
+	executor := NewExecutor(agent, Options{MaxIterations: 3})
+
+	handler := &answerHandler{}
+	out, err := executor.Call(context.Background(), map[string]any{"input": "where?"}, chains.WithCallback(handler))
+	if err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if want := "Paris: été ☀"; out["output"] != want {
+		t.Errorf("output = %v, want %q", out["output"], want)
+	}
+
+	_, raw := handler.recorded()
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if got := strings.Join(handler.answer, ""); got != out["output"] {
+		t.Errorf("answer chunks = %q, want the final answer", handler.answer)
+	}
+	if want := plan + `{"Thought": "done", "FinalAnswer": ""}`; handler.thinking != want {
+		t.Errorf("thinking = %q, want %q", handler.thinking, want)
+	}
+	if len(raw) != 0 {
+		t.Errorf("HandleStreamingFunc got %q, want the split chunks only", raw)
+	}
+}
@@ -0,0 +1,238 @@
+package concurrent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/tools"
+)
+
+const (
+	_defaultToolNameDistance = 2
+	_minToolNameContained    = 3
+	_closestToolNames        = 3
+)
+
+// resolveTool finds the tool the model meant by name. Names are tried, in
+// order, as they are (case-insensitive), as one of the ToolAliases, after
+// normalization, which drops case, spaces and punctuation, and then by
+// fuzzy matching: a normalized name containing or contained in the one of a
+// single tool, or the only tool within ToolNameDistance edits. A resolved
+// name is remembered for the rest of the run.
+func (e *Executor) resolveTool(ctx context.Context, run *runState, name string) (tools.Tool, bool) {
+	key := strings.ToUpper(name)
+	if tool, ok := run.nameToTool.Load(key); ok {
+		return tool.(tools.Tool), true //nolint:forcetypeassert
+	}
+
+	tool, how := e.matchTool(name)
+	if tool == nil {
+		return nil, false
+	}
+	run.nameToTool.Store(key, tool)
+	run.log(ctx, slog.LevelDebug, "tool name resolved",
+		slog.String("name", name),
+		slog.String("tool", tool.Name()),
+		slog.String("match", how))
+
+	return tool, true
+}
+
+// matchTool returns the tool matching name, if any, and how it matched.
+func (e *Executor) matchTool(name string) (tools.Tool, string) {
+	agentTools := e.Agent.GetTools()
+	byName := func(toolName string) tools.Tool {
+		for _, tool := range agentTools {
+			if strings.EqualFold(tool.Name(), toolName) {
+				return tool
+			}
+		}
+		return nil
+	}
+
+	normalized := normalizeToolName(name)
+	for alias, toolName := range e.ToolAliases {
+		if normalizeToolName(alias) == normalized {
+			if tool := byName(toolName); tool != nil {
+				return tool, "alias"
+			}
+		}
+	}
+	if tool := uniqueTool(agentTools, func(tool tools.Tool) bool {
+		return normalizeToolName(tool.Name()) == normalized
+	}); tool != nil {
+		return tool, "normalized"
+	}
+
+	maxDistance := e.ToolNameDistance
+	if maxDistance == 0 {
+		maxDistance = _defaultToolNameDistance
+	}
+	if maxDistance < 0 || normalized == "" {
+		return nil, ""
+	}
+	if tool := uniqueTool(agentTools, func(tool tools.Tool) bool {
+		toolName := normalizeToolName(tool.Name())
+		shorter := min(len(toolName), len(normalized))
+		return shorter >= _minToolNameContained &&
+			(strings.Contains(normalized, toolName) || strings.Contains(toolName, normalized))
+	}); tool != nil {
+		return tool, "contained"
+	}
+
+	var (
+		best     tools.Tool
+		bestDist = maxDistance + 1
+		tie      bool
+	)
+	for _, tool := range agentTools {
+		switch d := editDistance(normalized, normalizeToolName(tool.Name())); {
+		case d < bestDist:
+			best, bestDist, tie = tool, d, false
+		case d == bestDist:
+			tie = true
+		}
+	}
+	if best == nil || tie {
+		return nil, ""
+	}
+
+	return best, "distance"
+}
+
+// uniqueTool returns the only tool satisfying match, nil if there are none
+// or several.
+func uniqueTool(agentTools []tools.Tool, match func(tools.Tool) bool) tools.Tool {
+	var found tools.Tool
+	for _, tool := range agentTools {
+		if !match(tool) {
+			continue
+		}
+		if found != nil {
+			return nil
+		}
+		found = tool
+	}
+
+	return found
+}
+
+// resolveAction renames the tool of action to the name of the tool it
+// resolves to, so that policies, limits, events and the scratchpad use it.
+func (e *Executor) resolveAction(ctx context.Context, run *runState, action schema.AgentAction) schema.AgentAction {
+	tool, ok := e.resolveTool(ctx, run, action.Tool)
+	if !ok || tool.Name() == action.Tool {
+		return action
+	}
+	resolved := action
+	resolved.Tool = tool.Name()
+	if action.Log == actionLog(action) {
+		resolved.Log = actionLog(resolved)
+	}
+
+	return resolved
+}
+
+// resolveActions returns the actions with their tools resolved.
+func (e *Executor) resolveActions(
+	ctx context.Context,
+	run *runState,
+	actions []schema.AgentAction,
+) []schema.AgentAction {
+	if len(actions) == 0 {
+		return actions
+	}
+	resolved := make([]schema.AgentAction, len(actions))
+	for i, action := range actions {
+		resolved[i] = e.resolveAction(ctx, run, action)
+	}
+
+	return resolved
+}
+
+// unknownToolObservation answers an action whose tool could not be
+// resolved with the closest valid tool names.
+func (e *Executor) unknownToolObservation(name string) string {
+	agentTools := e.Agent.GetTools()
+	normalized := normalizeToolName(name)
+	names := make([]string, 0, len(agentTools))
+	for _, tool := range agentTools {
+		names = append(names, tool.Name())
+	}
+	// Names are ranked by how well the given name fits inside them, then by
+	// their whole distance, so that "serch" is close to "google_serper".
+	closeness := func(toolName string) [2]int {
+		toolName = normalizeToolName(toolName)
+		return [2]int{editDistanceWithin(normalized, toolName), editDistance(normalized, toolName)}
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		ci, cj := closeness(names[i]), closeness(names[j])
+		return ci[0] < cj[0] || ci[0] == cj[0] && ci[1] < cj[1]
+	})
+	if len(names) > _closestToolNames {
+		names = names[:_closestToolNames]
+	}
+	if len(names) == 0 {
+		return fmt.Sprintf("%s is not a valid tool and no tools are available", name)
+	}
+
+	return fmt.Sprintf("%s is not a valid tool, the closest valid tools are: %s. Use one of the tool names exactly.",
+		name, strings.Join(names, ", "))
+}
+
+// normalizeToolName lower-cases name and drops everything but letters and
+// digits, so "Google Serper", "google-serper" and "google_serper" compare
+// equal.
+func normalizeToolName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// editDistance is the Levenshtein distance between a and b, in runes.
+func editDistance(a, b string) int {
+	return levenshtein(a, b, false)
+}
+
+// editDistanceWithin is the smallest edit distance between a and a part of b.
+func editDistanceWithin(a, b string) int {
+	return levenshtein(a, b, true)
+}
+
+// levenshtein computes the edit distance between a and b. When within is set,
+// the characters of b before and after the match are free.
+func levenshtein(a, b string, within bool) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	if !within {
+		for j := range prev {
+			prev[j] = j
+		}
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = slices.Min([]int{prev[j] + 1, cur[j-1] + 1, prev[j-1] + cost})
+		}
+		prev, cur = cur, prev
+	}
+	if within {
+		return slices.Min(prev)
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,100 @@
+package concurrent
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/tools"
+)
+
+func TestExecutorToolNameResolution(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		tool     string
+		wantTool string
+		wantObs  string
+	}{
+		{name: "exact", tool: "google_serper", wantTool: "google_serper", wantObs: "google_serper: q"},
+		{name: "alias", tool: "search", wantTool: "google_serper", wantObs: "google_serper: q"},
+		{name: "normalized", tool: "Google Serper", wantTool: "google_serper", wantObs: "google_serper: q"},
+		{name: "misspelled", tool: "qweathr", wantTool: "qweather", wantObs: "qweather: q"},
+		{
+			name:     "unknown",
+			tool:     "calculator",
+			wantTool: "calculator",
+			wantObs:  "calculator is not a valid tool, the closest valid tools are:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			agent := &scriptedAgent{
+				tools: []tools.Tool{newFakeTool("google_serper", nil), newFakeTool("qweather", nil)},
+				plans: [][]schema.AgentAction{actions(tt.tool, "q")},
+			}
+			executor := NewExecutor(agent, Options{
+				MaxIterations: 3,
+				ToolAliases:   map[string]string{"search": "google_serper"},
+			})
+
+			if _, err := executor.Call(context.Background(), map[string]any{"input": "q"}); err != nil {
+				t.Fatalf("Call: %v", err)
+			}
+			step := agent.planned()[1][0]
+			if step.Action.Tool != tt.wantTool || !strings.HasPrefix(step.Observation, tt.wantObs) {
+				t.Errorf("step = %s %q, want %s %q", step.Action.Tool, step.Observation, tt.wantTool, tt.wantObs)
+			}
+		})
+	}
+}
+
+func TestExecutorApproverSeesResolvedToolNames(t *testing.T) {
+	t.Parallel()
+
+	dangerous := newFakeTool("delete_records", nil)
+	agent := &scriptedAgent{
+		tools: []tools.Tool{dangerous, newFakeTool("search", nil)},
+		plans: [][]schema.AgentAction{{
+			{Tool: "Delete Records", ToolInput: "all"},
+			{Tool: "delete_recrds", ToolInput: "all"},
+			{Tool: "search", ToolInput: "q"},
+		}},
+	}
+	var (
+		mu   sync.Mutex
+		seen []string
+	)
+	executor := NewExecutor(agent, Options{
+		MaxIterations:      3,
+		ReuseActionResults: true,
+		Approver: ApproverFunc(func(_ context.Context, _ string, planned []schema.AgentAction) ([]Decision, error) {
+			decisions := make([]Decision, len(planned))
+			mu.Lock()
+			defer mu.Unlock()
+			for i, action := range planned {
+				seen = append(seen, action.Tool)
+				if action.Tool == "delete_records" {
+					decisions[i] = Decision{Verdict: VerdictReject, Reason: "not allowed"}
+				}
+			}
+			return decisions, nil
+		}),
+	})
+
+	if _, err := executor.Call(context.Background(), map[string]any{"input": "q"}); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if want := []string{"delete_records", "delete_records", "search"}; !slices.Equal(seen, want) {
+		t.Errorf("approver saw %q, want %q", seen, want)
+	}
+	if got := dangerous.calls.Load(); got != 0 {
+		t.Errorf("rejected tool called %d times", got)
+	}
+}
@@ -0,0 +1,67 @@
+package concurrent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// JSONLExporter writes every finished span as one JSON line, for offline
+// inspection of runs.
+type JSONLExporter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+var _ SpanProcessor = &JSONLExporter{}
+
+// NewJSONLExporter creates a JSONLExporter writing to w.
+func NewJSONLExporter(w io.Writer) *JSONLExporter {
+	return &JSONLExporter{w: w}
+}
+
+// OpenJSONLExporter creates a JSONLExporter appending to the file at path,
+// created if needed. Close the exporter to close the file.
+func OpenJSONLExporter(path string) (*JSONLExporter, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644) //nolint:mnd
+	if err != nil {
+		return nil, err
+	}
+
+	return &JSONLExporter{w: f, closer: f}, nil
+}
+
+func (e *JSONLExporter) OnStart(context.Context, Span) {}
+
+// OnEnd writes the span. Attributes that cannot be encoded are written with
+// their fmt representation.
+func (e *JSONLExporter) OnEnd(_ context.Context, span Span) {
+	line, err := json.Marshal(span)
+	if err != nil {
+		attributes := make(map[string]any, len(span.Attributes))
+		for k, v := range span.Attributes {
+			attributes[k] = fmt.Sprint(v)
+		}
+		span.Attributes = attributes
+		if line, err = json.Marshal(span); err != nil {
+			return
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, _ = e.w.Write(append(line, '\n'))
+}
+
+// Close closes the file opened by OpenJSONLExporter.
+func (e *JSONLExporter) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+
+	return e.closer.Close()
+}
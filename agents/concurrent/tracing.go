@@ -0,0 +1,235 @@
+package concurrent
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tmc/langchaingo/callbacks"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// SpanKind identifies what a Span measures.
+type SpanKind string
+
+const (
+	// SpanRun covers a whole executor run.
+	SpanRun SpanKind = "run"
+	// SpanIteration covers one plan and the actions it produced.
+	SpanIteration SpanKind = "iteration"
+	// SpanPlan covers the planning call of an iteration.
+	SpanPlan SpanKind = "plan"
+	// SpanLLM covers a model call reported by the callbacks of an LLM.
+	SpanLLM SpanKind = "llm"
+	// SpanChain covers a chain call reported by the callbacks of a chain.
+	SpanChain SpanKind = "chain"
+	// SpanAction covers an executed action, retries included.
+	SpanAction SpanKind = "action"
+)
+
+// Span is a timed operation of a run. Spans form a tree through ParentID:
+// run, iterations, plans with their model calls, and actions.
+type Span struct {
+	TraceID    string
+	SpanID     string
+	ParentID   string
+	Kind       SpanKind
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]any
+	// Err is the error the operation ended with, if any.
+	Err string
+}
+
+// Duration is the time between the start and the end of the span.
+func (s Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// SpanProcessor receives the spans of a Tracer as they start and end. The
+// methods are called concurrently for the parallel actions of a plan.
+type SpanProcessor interface {
+	OnStart(ctx context.Context, span Span)
+	OnEnd(ctx context.Context, span Span)
+}
+
+// Tracer records the spans of the runs of an Executor and hands them to its
+// processors, such as a JSONLExporter or the Processor of the otel
+// subpackage. Set it as the callbacks handler of the LLM or the agent as well
+// to get the model and chain calls as children of the plan spans.
+type Tracer struct {
+	callbacks.SimpleHandler
+
+	Processors []SpanProcessor
+
+	mu sync.Mutex
+	// callbackSpans holds the spans opened by start callbacks, keyed by
+	// parent span and kind, until the matching end callback.
+	callbackSpans map[callbackSpanKey]*activeSpan
+}
+
+var _ callbacks.Handler = &Tracer{}
+
+// NewTracer creates a Tracer sending its spans to the given processors.
+func NewTracer(processors ...SpanProcessor) *Tracer {
+	return &Tracer{Processors: processors}
+}
+
+type spanContextKey struct{}
+
+type callbackSpanKey struct {
+	parent string
+	kind   SpanKind
+}
+
+// activeSpan is a span that has started and not ended yet. A nil activeSpan
+// is a no-op, so call sites do not check whether tracing is enabled.
+type activeSpan struct {
+	tracer *Tracer
+	mu     sync.Mutex
+	span   Span
+}
+
+// start opens a span, child of the span in ctx if any, and returns ctx
+// carrying the new span.
+func (t *Tracer) start(
+	ctx context.Context,
+	kind SpanKind,
+	name string,
+	attributes map[string]any,
+) (context.Context, *activeSpan) {
+	if t == nil {
+		return ctx, nil
+	}
+	if attributes == nil {
+		attributes = make(map[string]any)
+	}
+	span := Span{
+		SpanID:     uuid.NewString(),
+		Kind:       kind,
+		Name:       name,
+		Start:      time.Now(),
+		Attributes: attributes,
+	}
+	if parent := spanFromContext(ctx); parent != nil {
+		span.TraceID = parent.span.TraceID
+		span.ParentID = parent.span.SpanID
+	} else {
+		span.TraceID = uuid.NewString()
+	}
+	for _, p := range t.Processors {
+		p.OnStart(ctx, span)
+	}
+	active := &activeSpan{tracer: t, span: span}
+
+	return context.WithValue(ctx, spanContextKey{}, active), active
+}
+
+func spanFromContext(ctx context.Context) *activeSpan {
+	span, _ := ctx.Value(spanContextKey{}).(*activeSpan)
+	return span
+}
+
+// set adds an attribute to the span.
+func (s *activeSpan) set(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Attributes[key] = value
+}
+
+// end closes the span with err and hands it to the processors.
+func (s *activeSpan) end(ctx context.Context, err error) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	span := s.span
+	span.End = time.Now()
+	if err != nil {
+		span.Err = err.Error()
+	}
+	attributes := make(map[string]any, len(span.Attributes))
+	for k, v := range span.Attributes {
+		attributes[k] = v
+	}
+	span.Attributes = attributes
+	s.mu.Unlock()
+
+	for _, p := range s.tracer.Processors {
+		p.OnEnd(ctx, span)
+	}
+}
+
+// startCallbackSpan opens a span for a start callback. Callbacks carry no
+// call id, so at most one span per kind is open under a parent span.
+func (t *Tracer) startCallbackSpan(ctx context.Context, kind SpanKind, attributes map[string]any) {
+	parent := spanFromContext(ctx)
+	if parent == nil {
+		return
+	}
+	_, span := t.start(ctx, kind, string(kind), attributes)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.callbackSpans == nil {
+		t.callbackSpans = make(map[callbackSpanKey]*activeSpan)
+	}
+	t.callbackSpans[callbackSpanKey{parent.span.SpanID, kind}] = span
+}
+
+func (t *Tracer) endCallbackSpan(ctx context.Context, kind SpanKind, attributes map[string]any, err error) {
+	parent := spanFromContext(ctx)
+	if parent == nil {
+		return
+	}
+	key := callbackSpanKey{parent.span.SpanID, kind}
+	t.mu.Lock()
+	span := t.callbackSpans[key]
+	delete(t.callbackSpans, key)
+	t.mu.Unlock()
+
+	for k, v := range attributes {
+		span.set(k, v)
+	}
+	span.end(ctx, err)
+}
+
+func (t *Tracer) HandleLLMGenerateContentStart(ctx context.Context, ms []llms.MessageContent) {
+	t.startCallbackSpan(ctx, SpanLLM, map[string]any{"messages": len(ms)})
+}
+
+func (t *Tracer) HandleLLMGenerateContentEnd(ctx context.Context, res *llms.ContentResponse) {
+	attributes := make(map[string]any)
+	if res != nil && len(res.Choices) > 0 {
+		attributes["output"] = res.Choices[0].Content
+		for k, v := range res.Choices[0].GenerationInfo {
+			attributes[k] = v
+		}
+	}
+	t.endCallbackSpan(ctx, SpanLLM, attributes, nil)
+}
+
+func (t *Tracer) HandleLLMError(ctx context.Context, err error) {
+	t.endCallbackSpan(ctx, SpanLLM, nil, err)
+}
+
+func (t *Tracer) HandleChainStart(ctx context.Context, _ map[string]any) {
+	t.startCallbackSpan(ctx, SpanChain, nil)
+}
+
+func (t *Tracer) HandleChainEnd(ctx context.Context, outputs map[string]any) {
+	attributes := make(map[string]any, len(outputs))
+	for k, v := range outputs {
+		attributes["output."+k] = v
+	}
+	t.endCallbackSpan(ctx, SpanChain, attributes, nil)
+}
+
+func (t *Tracer) HandleChainError(ctx context.Context, err error) {
+	t.endCallbackSpan(ctx, SpanChain, nil, err)
+}
@@ -0,0 +1,179 @@
+package concurrent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/tmc/langchaingo/callbacks"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/tools"
+)
+
+// recordingProcessor records the spans it gets.
+type recordingProcessor struct {
+	mu           sync.Mutex
+	started, end []Span
+}
+
+func (p *recordingProcessor) OnStart(_ context.Context, span Span) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.started = append(p.started, span)
+}
+
+func (p *recordingProcessor) OnEnd(_ context.Context, span Span) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.end = append(p.end, span)
+}
+
+// callbackLLM is a fakeLLM reporting its calls to a callbacks handler, like
+// the langchaingo models do.
+type callbackLLM struct {
+	*fakeLLM
+	handler callbacks.Handler
+}
+
+func (m callbackLLM) GenerateContent(
+	ctx context.Context,
+	messages []llms.MessageContent,
+	options ...llms.CallOption,
+) (*llms.ContentResponse, error) {
+	m.handler.HandleLLMGenerateContentStart(ctx, messages)
+	resp, err := m.fakeLLM.GenerateContent(ctx, messages, options...)
+	if err != nil {
+		m.handler.HandleLLMError(ctx, err)
+		return nil, err
+	}
+	m.handler.HandleLLMGenerateContentEnd(ctx, resp)
+
+	return resp, nil
+}
+
+func TestExecutorTracer(t *testing.T) {
+	t.Parallel()
+
+	recorder := &recordingProcessor{}
+	var lines bytes.Buffer
+	tracer := NewTracer(recorder, NewJSONLExporter(&lines))
+	llm := callbackLLM{
+		fakeLLM: &fakeLLM{replies: []*llms.ContentResponse{
+			textReply(`{"Actions": [{"Action": "search", "ActionInput": "a"}, {"Action": "search", "ActionInput": "b"}]}`),
+			textReply(`{"FinalAnswer": "done"}`),
+		}},
+		handler: tracer,
+	}
+	agent, err := NewConcurrentAgentWithOptions(llm, []tools.Tool{newFakeTool("search", nil)}, WithCallbacksHandler(tracer))
+	if err != nil {
+		t.Fatalf("NewConcurrentAgentWithOptions: %v", err)
+	}
+	executor := NewExecutor(agent, Options{MaxIterations: 3, Tracer: tracer})
+
+	if _, err := executor.Call(context.Background(), map[string]any{"input": "q"}); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.started) != len(recorder.end) {
+		t.Errorf("%d spans started, %d ended", len(recorder.started), len(recorder.end))
+	}
+	byID := make(map[string]Span, len(recorder.end))
+	count := make(map[SpanKind]int)
+	for _, span := range recorder.end {
+		byID[span.SpanID] = span
+		count[span.Kind]++
+	}
+	wantCount := map[SpanKind]int{SpanRun: 1, SpanIteration: 2, SpanPlan: 2, SpanChain: 2, SpanLLM: 2, SpanAction: 2}
+	for kind, want := range wantCount {
+		if count[kind] != want {
+			t.Errorf("%d %s spans, want %d", count[kind], kind, want)
+		}
+	}
+
+	wantParent := map[SpanKind]SpanKind{
+		SpanIteration: SpanRun,
+		SpanPlan:      SpanIteration,
+		SpanChain:     SpanPlan,
+		SpanLLM:       SpanPlan,
+		SpanAction:    SpanIteration,
+	}
+	var run Span
+	for _, span := range recorder.end {
+		if span.Kind == SpanRun {
+			run = span
+		}
+	}
+	for _, span := range recorder.end {
+		if span.TraceID != run.TraceID {
+			t.Errorf("%s span in trace %s, want %s", span.Kind, span.TraceID, run.TraceID)
+		}
+		if span.End.Before(span.Start) {
+			t.Errorf("%s span ends before it starts", span.Kind)
+		}
+		if span.Kind == SpanRun {
+			if span.ParentID != "" {
+				t.Errorf("run span has parent %s", span.ParentID)
+			}
+			continue
+		}
+		if parent := byID[span.ParentID]; parent.Kind != wantParent[span.Kind] {
+			t.Errorf("%s span %s has a %q parent, want %s", span.Kind, span.Name, parent.Kind, wantParent[span.Kind])
+		}
+	}
+
+	// The exporter wrote the same spans.
+	var exported []string
+	scanner := bufio.NewScanner(&lines)
+	for scanner.Scan() {
+		var span Span
+		if err := json.Unmarshal(scanner.Bytes(), &span); err != nil {
+			t.Fatalf("exported line %q: %v", scanner.Text(), err)
+		}
+		exported = append(exported, span.SpanID)
+	}
+	ended := make([]string, 0, len(recorder.end))
+	for _, span := range recorder.end {
+		ended = append(ended, span.SpanID)
+	}
+	slices.Sort(exported)
+	slices.Sort(ended)
+	if !slices.Equal(exported, ended) {
+		t.Errorf("exported %d spans, want the %d ended spans", len(exported), len(ended))
+	}
+}
+
+func TestJSONLExporter(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	exporter := NewJSONLExporter(&buf)
+	exporter.OnEnd(context.Background(), Span{SpanID: "1", Kind: SpanAction, Attributes: map[string]any{"input": "x"}})
+	exporter.OnEnd(context.Background(), Span{SpanID: "2", Kind: SpanAction, Attributes: map[string]any{
+		"tool":    "search",
+		"unknown": func() {},
+	}, Err: "boom"})
+
+	var spans []Span
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var span Span
+		if err := json.Unmarshal(line, &span); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		spans = append(spans, span)
+	}
+	if len(spans) != 2 || spans[0].Attributes["input"] != "x" {
+		t.Fatalf("spans = %+v, want both spans", spans)
+	}
+	if got := spans[1]; got.Err != "boom" || got.Attributes["tool"] != "search" || got.Attributes["unknown"] == nil {
+		t.Errorf("span = %+v, want the attributes that cannot be encoded written with fmt", got)
+	}
+	if err := exporter.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+}
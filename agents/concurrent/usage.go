@@ -0,0 +1,206 @@
+package concurrent
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"sync"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+const _usageOutputKey = "usage"
+
+const _tokensPerPriceUnit = 1_000_000
+
+// TokenUsage counts the model calls of a run and their tokens.
+type TokenUsage struct {
+	Calls            int
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+}
+
+func (u *TokenUsage) add(other TokenUsage) {
+	u.Calls += other.Calls
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+}
+
+// Usage is what a run consumed: the tokens reported in the generation info
+// of the model responses, overall and per model, and the number of calls
+// made to each tool, retries included. Models that report no usage only
+// count their calls.
+type Usage struct {
+	TokenUsage
+	// Models holds the usage per model name. Calls whose model is not set in
+	// the call options are counted under "".
+	Models    map[string]TokenUsage
+	ToolCalls map[string]int
+	// Cost is the estimated cost of the run according to the executor's
+	// Prices, zero without prices.
+	Cost float64
+}
+
+// ModelPrice is the price of a model per million tokens.
+type ModelPrice struct {
+	Prompt     float64
+	Completion float64
+}
+
+// Prices is used to estimate the cost of the runs. All prices are in the
+// same currency.
+type Prices struct {
+	// Models maps model names to their price. The "" entry prices the calls
+	// of models without an entry, e.g. when the model is not set in the call
+	// options.
+	Models map[string]ModelPrice
+	// Tools maps tool names to the price of one call.
+	Tools map[string]float64
+}
+
+func (p Prices) cost(usage Usage) float64 {
+	var cost float64
+	for model, tokens := range usage.Models {
+		price, ok := p.Models[model]
+		if !ok {
+			price = p.Models[""]
+		}
+		cost += (float64(tokens.PromptTokens)*price.Prompt +
+			float64(tokens.CompletionTokens)*price.Completion) / _tokensPerPriceUnit
+	}
+	for tool, calls := range usage.ToolCalls {
+		cost += float64(calls) * p.Tools[tool]
+	}
+
+	return cost
+}
+
+// UsageHandler is implemented by callbacks handlers that want the usage of
+// each run of the Executor they are set on. HandleUsage is called when the
+// run ends, whether it succeeded or not.
+type UsageHandler interface {
+	HandleUsage(ctx context.Context, runID string, usage Usage)
+}
+
+// usageRecorder accumulates the usage of a run. It is carried by the context
+// so that the agents can report their model calls; the usage of a nested run
+// is added to the enclosing run as well.
+type usageRecorder struct {
+	parent *usageRecorder
+
+	mu    sync.Mutex
+	usage Usage
+}
+
+type usageRecorderKey struct{}
+
+func withUsageRecorder(ctx context.Context, r *usageRecorder) context.Context {
+	r.parent, _ = ctx.Value(usageRecorderKey{}).(*usageRecorder)
+	return context.WithValue(ctx, usageRecorderKey{}, r)
+}
+
+func (r *usageRecorder) addTokens(model string, tokens TokenUsage) {
+	for ; r != nil; r = r.parent {
+		r.mu.Lock()
+		r.usage.TokenUsage.add(tokens)
+		if r.usage.Models == nil {
+			r.usage.Models = make(map[string]TokenUsage)
+		}
+		modelUsage := r.usage.Models[model]
+		modelUsage.add(tokens)
+		r.usage.Models[model] = modelUsage
+		r.mu.Unlock()
+	}
+}
+
+func (r *usageRecorder) addToolCall(tool string) {
+	for ; r != nil; r = r.parent {
+		r.mu.Lock()
+		if r.usage.ToolCalls == nil {
+			r.usage.ToolCalls = make(map[string]int)
+		}
+		r.usage.ToolCalls[tool]++
+		r.mu.Unlock()
+	}
+}
+
+// snapshot returns a copy of the usage with its estimated cost.
+func (r *usageRecorder) snapshot(prices Prices) Usage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	usage := r.usage
+	usage.Models = maps.Clone(r.usage.Models)
+	usage.ToolCalls = maps.Clone(r.usage.ToolCalls)
+	usage.Cost = prices.cost(usage)
+
+	return usage
+}
+
+// recordModelUsage adds the usage reported by a model response to the run
+// of ctx, if any.
+func recordModelUsage(ctx context.Context, options []llms.CallOption, resp *llms.ContentResponse) {
+	r, ok := ctx.Value(usageRecorderKey{}).(*usageRecorder)
+	if !ok {
+		return
+	}
+	var callOptions llms.CallOptions
+	for _, opt := range options {
+		opt(&callOptions)
+	}
+
+	tokens := TokenUsage{Calls: 1}
+	if resp != nil && len(resp.Choices) > 0 {
+		// Providers repeat the usage of the response on every choice.
+		info := resp.Choices[0].GenerationInfo
+		tokens.PromptTokens = intFromInfo(info, "PromptTokens", "InputTokens", "prompt_tokens", "input_tokens")
+		tokens.CompletionTokens = intFromInfo(info,
+			"CompletionTokens", "OutputTokens", "completion_tokens", "output_tokens")
+		tokens.TotalTokens = intFromInfo(info, "TotalTokens", "total_tokens")
+		if tokens.TotalTokens == 0 {
+			tokens.TotalTokens = tokens.PromptTokens + tokens.CompletionTokens
+		}
+	}
+	r.addTokens(callOptions.Model, tokens)
+}
+
+// intFromInfo returns the first of the keys found in the generation info as
+// an int. Providers use different keys and number types.
+func intFromInfo(info map[string]any, keys ...string) int {
+	for _, key := range keys {
+		switch v := info[key].(type) {
+		case int:
+			return v
+		case int32:
+			return int(v)
+		case int64:
+			return int(v)
+		case float64:
+			return int(v)
+		case json.Number:
+			n, _ := v.Int64()
+			return int(n)
+		}
+	}
+
+	return 0
+}
+
+// usageModel reports the usage of its calls to the run of their context.
+type usageModel struct {
+	llms.Model
+}
+
+func (m usageModel) GenerateContent(
+	ctx context.Context,
+	messages []llms.MessageContent,
+	options ...llms.CallOption,
+) (*llms.ContentResponse, error) {
+	resp, err := m.Model.GenerateContent(ctx, messages, options...)
+	if err == nil {
+		recordModelUsage(ctx, options, resp)
+	}
+
+	return resp, err
+}
@@ -39,7 +39,7 @@ func New(opts ...Option) (*Tool, error) {
 	}
 
 	return &Tool{
-		client: internal.New(options.apiKey, options.count, options.debug),
+		client: internal.New(options.apiKey, options.count, options.debug, options.logger),
 	}, nil
 }
 
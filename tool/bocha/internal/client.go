@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+
 	"github.com/go-resty/resty/v2"
-	"strconv"
+	"github.com/thinkdb1/langchaingo-ext/tool/internal/httplog"
 )
 
 const _url = "https://api.bochaai.com/v1/web-search"
@@ -33,19 +35,19 @@ type BochaResp struct {
 type Client struct {
 	apiKey string
 	count  uint
-	debug  bool
+	http   *resty.Client
 }
 
-func New(apiKey string, count uint, debug bool) *Client {
+func New(apiKey string, count uint, debug bool, logger *slog.Logger) *Client {
 	return &Client{
 		apiKey: apiKey,
 		count:  count,
-		debug:  debug,
+		http:   httplog.New(logger, debug, apiKey),
 	}
 }
 
 func (s *Client) Search(ctx context.Context, query string) (string, error) {
-	r := resty.New().R().SetDebug(s.debug).SetContext(ctx)
+	r := s.http.R().SetContext(ctx)
 	webRes := new(BochaResp)
 	resp, err := r.SetHeader("Authorization", "Bearer "+s.apiKey).
 		SetHeader("Content-Type", "application/json").
@@ -59,7 +61,7 @@ func (s *Client) Search(ctx context.Context, query string) (string, error) {
 	}
 
 	if resp.StatusCode() != 200 {
-		return "", errors.New("search in bocha api, status code: " + strconv.Itoa(resp.StatusCode()))
+		return "", httplog.NewStatusError("search in bocha api", resp)
 	}
 	if webRes.Code != 200 {
 		return "", errors.New("error from Bocha API: {" + resp.String() + "or 'Unknown error'}")
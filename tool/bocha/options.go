@@ -1,9 +1,12 @@
 package bocha
 
+import "log/slog"
+
 type options struct {
 	apiKey string
 	count  uint
 	debug  bool
+	logger *slog.Logger
 }
 
 type Option func(*options)
@@ -23,9 +26,18 @@ func WithCount(count uint) Option {
 	}
 }
 
-// WithDebug Enable debug mode
+// WithDebug adds the headers and bodies of the HTTP calls to the debug level
+// log entries.
 func WithDebug(debug bool) Option {
 	return func(opts *options) {
 		opts.debug = debug
 	}
 }
+
+// WithLogger sets the logger of the HTTP calls, slog.Default() if not set.
+// API keys are redacted from the log entries.
+func WithLogger(logger *slog.Logger) Option {
+	return func(opts *options) {
+		opts.logger = logger
+	}
+}
@@ -42,7 +42,7 @@ func New(opts ...Option) (*Tool, error) {
 	}
 
 	return &Tool{
-		client: internal.New(options.apiKey, options.searchType, options.debug),
+		client: internal.New(options.apiKey, options.searchType, options.debug, options.logger),
 	}, nil
 }
 
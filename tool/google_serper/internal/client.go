@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+
 	"github.com/go-resty/resty/v2"
-	"strconv"
+	"github.com/thinkdb1/langchaingo-ext/tool/internal/httplog"
 )
 
 const (
@@ -30,14 +32,14 @@ type searchInf interface {
 }
 type Client struct {
 	apiKey string
-	debug  bool
+	http   *resty.Client
 	inf    searchInf
 }
 
-func New(apiKey string, searchType uint, debug bool) *Client {
+func New(apiKey string, searchType uint, debug bool, logger *slog.Logger) *Client {
 	c := &Client{
 		apiKey: apiKey,
-		debug:  debug,
+		http:   httplog.New(logger, debug, apiKey),
 	}
 	switch searchType {
 	case TYPE_SEARCH:
@@ -53,7 +55,7 @@ func New(apiKey string, searchType uint, debug bool) *Client {
 }
 
 func (s *Client) Search(ctx context.Context, query string) (string, error) {
-	r := resty.New().R().SetDebug(s.debug).SetContext(ctx)
+	r := s.http.R().SetContext(ctx)
 	s.inf.HandleRequest(r)
 	resp, err := r.SetHeader("X-API-KEY", s.apiKey).
 		SetHeader("Content-Type", "application/json").
@@ -65,7 +67,7 @@ func (s *Client) Search(ctx context.Context, query string) (string, error) {
 	}
 
 	if resp.StatusCode() != 200 {
-		return "", errors.New("search in google_serper api, status code: " + strconv.Itoa(resp.StatusCode()))
+		return "", httplog.NewStatusError("search in google_serper api", resp)
 	}
 	return s.inf.OutputResponse()
 }
@@ -1,8 +1,11 @@
 package google_serper
 
+import "log/slog"
+
 type options struct {
 	apiKey     string
 	debug      bool
+	logger     *slog.Logger
 	searchType uint
 }
 
@@ -16,7 +19,8 @@ func WithAPIKey(apiKey string) Option {
 	}
 }
 
-// WithDebug Enable debug mode
+// WithDebug adds the headers and bodies of the HTTP calls to the debug level
+// log entries.
 func WithDebug(debug bool) Option {
 	return func(opts *options) {
 		opts.debug = debug
@@ -29,3 +33,11 @@ func WithSearchType(k uint) Option {
 		opts.searchType = k
 	}
 }
+
+// WithLogger sets the logger of the HTTP calls, slog.Default() if not set.
+// API keys are redacted from the log entries.
+func WithLogger(logger *slog.Logger) Option {
+	return func(opts *options) {
+		opts.logger = logger
+	}
+}
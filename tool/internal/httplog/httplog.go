@@ -0,0 +1,107 @@
+// Package httplog logs the HTTP calls of the tool clients with log/slog,
+// redacting credentials, and reports the unexpected statuses they get.
+package httplog
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const _redacted = "[REDACTED]"
+
+// _credentialHeaders are always redacted, whatever their value.
+var _credentialHeaders = []string{"Authorization", "X-Api-Key", "X-Qw-Api-Key"} //nolint:gochecknoglobals
+
+// New returns a resty client logging every call on logger, slog.Default()
+// if nil: calls at debug level and failed calls at warn level. With bodies
+// set, the headers and bodies are logged too, replacing resty's debug dumps.
+// The secrets and the credential headers never appear in the output.
+func New(logger *slog.Logger, bodies bool, secrets ...string) *resty.Client {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	r := redactor{secrets: secrets}
+
+	client := resty.New()
+	client.OnAfterResponse(func(_ *resty.Client, resp *resty.Response) error {
+		req := resp.Request
+		attrs := []any{
+			slog.String("method", req.Method),
+			slog.String("url", r.redact(req.URL)),
+			slog.Int("status", resp.StatusCode()),
+			slog.Duration("duration", resp.Time()),
+		}
+		if bodies {
+			attrs = append(attrs,
+				slog.Any("request_headers", r.headers(req.Header)),
+				slog.String("request_body", r.body(req.Body)),
+				slog.String("response_body", r.redact(resp.String())),
+			)
+		}
+		level := slog.LevelDebug
+		if resp.IsError() {
+			level = slog.LevelWarn
+		}
+		logger.Log(req.Context(), level, "http call", attrs...)
+		return nil
+	})
+	client.OnError(func(req *resty.Request, err error) {
+		logger.WarnContext(req.Context(), "http call failed",
+			slog.String("method", req.Method),
+			slog.String("url", r.redact(req.URL)),
+			slog.String("error", r.redact(err.Error())),
+		)
+	})
+
+	return client
+}
+
+type redactor struct {
+	secrets []string
+}
+
+func (r redactor) redact(s string) string {
+	for _, secret := range r.secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, _redacted)
+		}
+	}
+
+	return s
+}
+
+func (r redactor) headers(header http.Header) map[string]string {
+	out := make(map[string]string, len(header))
+	for key := range header {
+		out[key] = r.redact(header.Get(key))
+	}
+	for _, key := range _credentialHeaders {
+		if header.Get(key) != "" {
+			out[http.CanonicalHeaderKey(key)] = _redacted
+		}
+	}
+
+	return out
+}
+
+func (r redactor) body(body any) string {
+	switch b := body.(type) {
+	case nil:
+		return ""
+	case string:
+		return r.redact(b)
+	case []byte:
+		return r.redact(string(b))
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return r.redact(fmt.Sprint(body))
+	}
+
+	return r.redact(string(data))
+}
@@ -0,0 +1,137 @@
+package httplog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const _secret = "s3cr3t-key"
+
+// records decodes the JSON log lines written to buf.
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		out = append(out, record)
+	}
+
+	return out
+}
+
+func TestNewRedacts(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != _secret || r.Header.Get("X-Qw-Api-Key") != _secret {
+			t.Errorf("the request did not carry the key: %s %v", r.URL, r.Header)
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error": "bad key ` + _secret + `"}`))
+	}))
+	defer server.Close()
+
+	for _, bodies := range []bool{false, true} {
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		client := New(logger, bodies, _secret)
+
+		resp, err := client.R().
+			SetContext(context.Background()).
+			SetQueryParam("key", _secret).
+			SetHeader("Authorization", "Bearer "+_secret).
+			SetHeader("X-Api-Key", "header-only-credential").
+			SetHeader("X-Qw-Api-Key", _secret).
+			SetBody(map[string]string{"query": "paris", "key": _secret}).
+			Post(server.URL + "/search")
+		if err != nil {
+			t.Fatalf("bodies %v: Post: %v", bodies, err)
+		}
+		if !strings.Contains(resp.String(), _secret) {
+			t.Fatalf("bodies %v: the response reached the caller redacted: %s", bodies, resp.String())
+		}
+
+		out := buf.String()
+		if strings.Contains(out, _secret) || strings.Contains(out, "header-only-credential") {
+			t.Errorf("bodies %v: the log leaks a credential: %s", bodies, out)
+		}
+		logged := records(t, &buf)
+		if len(logged) != 1 {
+			t.Fatalf("bodies %v: %d log records, want 1: %s", bodies, len(logged), out)
+		}
+		record := logged[0]
+		if record["level"] != "WARN" || record["status"] != float64(http.StatusUnauthorized) {
+			t.Errorf("bodies %v: level %v status %v, want a warning for 401", bodies, record["level"], record["status"])
+		}
+		if url, _ := record["url"].(string); !strings.Contains(url, "key="+_redacted) {
+			t.Errorf("bodies %v: url = %q, want the key redacted", bodies, url)
+		}
+
+		_, hasBody := record["request_body"]
+		if hasBody != bodies {
+			t.Errorf("bodies %v: request_body logged = %v", bodies, hasBody)
+		}
+		if !bodies {
+			continue
+		}
+		headers, _ := record["request_headers"].(map[string]any)
+		for _, key := range _credentialHeaders {
+			if headers[key] != _redacted {
+				t.Errorf("header %s = %v, want %s", key, headers[key], _redacted)
+			}
+		}
+		if body, _ := record["request_body"].(string); !strings.Contains(body, `"key":"`+_redacted+`"`) {
+			t.Errorf("request_body = %q, want the key redacted", body)
+		}
+		if body, _ := record["response_body"].(string); !strings.Contains(body, "bad key "+_redacted) {
+			t.Errorf("response_body = %q, want the key redacted", body)
+		}
+	}
+}
+
+func TestNewRedactsErrors(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	_, err := New(logger, false, _secret).R().Get(url + "/search?key=" + _secret)
+	if err == nil {
+		t.Fatal("Get on a closed server succeeded")
+	}
+
+	out := buf.String()
+	if strings.Contains(out, _secret) {
+		t.Errorf("the log leaks the key: %s", out)
+	}
+	if record := records(t, &buf)[0]; record["msg"] != "http call failed" || !strings.Contains(record["url"].(string), _redacted) {
+		t.Errorf("record = %v, want the failed call with the key redacted", record)
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	t.Parallel()
+
+	var err error = &StatusError{Op: "search in bocha api", Code: http.StatusTooManyRequests}
+	var status interface{ StatusCode() int }
+	if !errors.As(err, &status) || status.StatusCode() != http.StatusTooManyRequests {
+		t.Errorf("StatusCode of %v = %v, want 429", err, status)
+	}
+	if want := "search in bocha api, status code: 429"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
@@ -0,0 +1,30 @@
+package httplog
+
+import (
+	"strconv"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// StatusError is returned by the tool clients when an API answers with an
+// unexpected status. Its StatusCode method lets callers, such as the retries
+// of the concurrent executor, tell transient failures from the others.
+type StatusError struct {
+	// Op names the failed call, e.g. "search in bocha api".
+	Op   string
+	Code int
+}
+
+// NewStatusError returns the StatusError of resp.
+func NewStatusError(op string, resp *resty.Response) *StatusError {
+	return &StatusError{Op: op, Code: resp.StatusCode()}
+}
+
+func (e *StatusError) Error() string {
+	return e.Op + ", status code: " + strconv.Itoa(e.Code)
+}
+
+// StatusCode returns the HTTP status of the response.
+func (e *StatusError) StatusCode() int {
+	return e.Code
+}
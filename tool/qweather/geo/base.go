@@ -39,7 +39,7 @@ func New(opts ...Option) (*Tool, error) {
 	}
 
 	return &Tool{
-		client: internal.New(Opts.apiKey, Opts.debug, Opts.number, Opts.lang),
+		client: internal.New(Opts.apiKey, Opts.debug, Opts.number, Opts.lang, Opts.logger),
 	}, nil
 }
 
@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/go-resty/resty/v2"
+	"log/slog"
 	"net/url"
-	"strconv"
 	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/thinkdb1/langchaingo-ext/tool/internal/httplog"
 )
 
 const _url = "https://geoapi.qweather.com/v2/city/lookup?"
@@ -41,15 +43,15 @@ type Location struct {
 
 type Client struct {
 	apiKey string
-	debug  bool
+	http   *resty.Client
 	number uint
 	lang   string //language
 }
 
-func New(apiKey string, debug bool, number uint, lang string) *Client {
+func New(apiKey string, debug bool, number uint, lang string, logger *slog.Logger) *Client {
 	return &Client{
 		apiKey: apiKey,
-		debug:  debug,
+		http:   httplog.New(logger, debug, apiKey),
 		number: number,
 		lang:   lang, //language
 	}
@@ -64,7 +66,7 @@ func (s *Client) Search(ctx context.Context, query string) (string, error) {
 	if s.lang != "" {
 		requestUri += "&lang=" + s.lang
 	}
-	r := resty.New().R().SetDebug(s.debug).SetContext(ctx)
+	r := s.http.R().SetContext(ctx)
 	webRes := new(Resp)
 	resp, err := r.SetHeader("X-QW-Api-Key", s.apiKey).
 		SetHeader("User-Agent", "ext").
@@ -75,7 +77,7 @@ func (s *Client) Search(ctx context.Context, query string) (string, error) {
 	}
 
 	if resp.StatusCode() != 200 {
-		return "", errors.New("search in q-geo api, status code: " + strconv.Itoa(resp.StatusCode()))
+		return "", httplog.NewStatusError("search in q-geo api", resp)
 	}
 	if webRes.Error != nil {
 		return "", errors.New("error from q-geo API: {" + webRes.Error.Title + ":" + webRes.Error.Detail + "}")
@@ -36,7 +36,7 @@ func New(opts ...Option) (*Tool, error) {
 	}
 
 	return &Tool{
-		client: internal.New(Opts.apiKey, Opts.debug, Opts.unit, Opts.lang),
+		client: internal.New(Opts.apiKey, Opts.debug, Opts.unit, Opts.lang, Opts.logger),
 	}, nil
 }
 
@@ -47,8 +47,8 @@ func (t Tool) Name() string {
 func (t Tool) Description() string {
 	return `
 	Use the q-weather API to perform a city weather search.
-Input is a json Serialized multiple strings: .
-"{\"longitude\":10.111,\"latitude\":10.111,\"city\":\"city name for search\"}"
+Input is a JSON object with the coordinates and the name of the city:
+{"longitude":10.111,"latitude":10.111,"city":"city name for search"}
 
 Return: A list of weather information
 	[
@@ -72,6 +72,20 @@ Return: A list of weather information
 `
 }
 
+// InputSchema is the JSON Schema of the input of the tool, so that agents
+// supporting it pass the input as a JSON object and validate it.
+func (t Tool) InputSchema() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"longitude": map[string]any{"type": "number", "minimum": -180, "maximum": 180, "description": "longitude of the city"},
+			"latitude":  map[string]any{"type": "number", "minimum": -90, "maximum": 90, "description": "latitude of the city"},
+			"city":      map[string]any{"type": "string", "description": "name of the city"},
+		},
+		"required": []string{"longitude", "latitude", "city"},
+	}
+}
+
 func (t Tool) Call(ctx context.Context, input string) (string, error) {
 	if t.CallbacksHandler != nil {
 		t.CallbacksHandler.HandleToolStart(ctx, input)
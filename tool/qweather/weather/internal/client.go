@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/go-resty/resty/v2"
-	"strconv"
+	"log/slog"
 	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/thinkdb1/langchaingo-ext/tool/internal/httplog"
 )
 
 const _url = "https://devapi.qweather.com/v7/weather/7d?"
@@ -59,15 +61,15 @@ type DailyItem struct {
 
 type Client struct {
 	apiKey string
-	debug  bool
+	http   *resty.Client
 	uint   string
 	lang   string //language
 }
 
-func New(apiKey string, debug bool, uint string, lang string) *Client {
+func New(apiKey string, debug bool, uint string, lang string, logger *slog.Logger) *Client {
 	return &Client{
 		apiKey: apiKey,
-		debug:  debug,
+		http:   httplog.New(logger, debug, apiKey),
 		uint:   uint,
 		lang:   lang, //language
 	}
@@ -80,19 +82,23 @@ type request struct {
 }
 
 func (s *Client) Search(ctx context.Context, query string) (string, error) {
-	query = strings.Replace(query, "`", "", -1)
-	query = strings.Replace(query, "json", "", 1)
-	query = strings.TrimSpace(query)
 	req := new(request)
-	if err := json.Unmarshal([]byte(query), req); err != nil {
-		return "", fmt.Errorf("q-weather query to struct err: %w", err)
+	// Object inputs arrive as plain JSON: the concurrent executor unwraps
+	// fenced ones, but other callers may still pass a ```json block.
+	if err := json.Unmarshal([]byte(strings.TrimSpace(query)), req); err != nil {
+		query = strings.Replace(query, "`", "", -1)
+		query = strings.Replace(query, "json", "", 1)
+		query = strings.TrimSpace(query)
+		if err := json.Unmarshal([]byte(query), req); err != nil {
+			return "", fmt.Errorf("q-weather query to struct err: %w", err)
+		}
 	}
 
 	requestUri := fmt.Sprintf(_url+"location=%.2f,%.2f&unit=%s", req.Longitude, req.Latitude, s.uint)
 	if s.lang != "" {
 		requestUri += "&lang=" + s.lang
 	}
-	r := resty.New().R().SetDebug(s.debug).SetContext(ctx)
+	r := s.http.R().SetContext(ctx)
 	webRes := new(Resp)
 	resp, err := r.SetHeader("X-QW-Api-Key", s.apiKey).
 		SetHeader("User-Agent", "ext").
@@ -103,7 +109,7 @@ func (s *Client) Search(ctx context.Context, query string) (string, error) {
 	}
 
 	if resp.StatusCode() != 200 {
-		return "", errors.New("search in q-weather api, status code: " + strconv.Itoa(resp.StatusCode()))
+		return "", httplog.NewStatusError("search in q-weather api", resp)
 	}
 
 	if webRes.Error != nil {
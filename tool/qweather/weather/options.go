@@ -1,9 +1,12 @@
 package weather
 
+import "log/slog"
+
 type options struct {
 	apiKey string //X-QW-Api-Key
 	lang   string //language
 	debug  bool
+	logger *slog.Logger
 	unit   string //unit=m（公制单位，默认）和unit=i（英制单位）
 }
 
@@ -30,9 +33,18 @@ func language(c string) Option {
 	}
 }
 
-// WithDebug Enable debug mode
+// WithDebug adds the headers and bodies of the HTTP calls to the debug level
+// log entries.
 func WithDebug(debug bool) Option {
 	return func(opts *options) {
 		opts.debug = debug
 	}
 }
+
+// WithLogger sets the logger of the HTTP calls, slog.Default() if not set.
+// API keys are redacted from the log entries.
+func WithLogger(logger *slog.Logger) Option {
+	return func(opts *options) {
+		opts.logger = logger
+	}
+}